@@ -0,0 +1,391 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Archive formats.
+const (
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+// ArchiveConfig configures the archive stage of Dist.
+// For each platform directory(ie. dist/my_cmd_linux_amd64), the archive(ie. my_cmd_linux_amd64.tar.gz)
+// that contains the binary and the CREDITS file is created(or updated).
+type ArchiveConfig struct {
+	// Format is ArchiveTarGz or ArchiveZip. The archive stage is disabled if it is empty.
+	Format string
+	// Dir is the directory of the archives. OutDir is used if it is empty.
+	Dir string
+	// CreditsPath is the path of the CREDITS file(the first output) in the archive. "CREDITS" is used if it is empty.
+	// The other outputs are placed in the same directory by their BaseName(ie. CREDITS.json).
+	CreditsPath string
+	// ModTime is the mtime of the entries that are added.
+	// SOURCE_DATE_EPOCH(or 1980-01-01 if it is not set) is used if it is zero.
+	ModTime time.Time
+	// Checksums is the file name of the checksums of the archives in Dir. "checksums.txt" is used if it is empty.
+	// Only the lines of the archives are updated, the other lines are kept.
+	Checksums string
+}
+
+func (a ArchiveConfig) withDefault(outDir string) ArchiveConfig {
+	if a.Dir == "" {
+		a.Dir = outDir
+	}
+	if a.CreditsPath == "" {
+		a.CreditsPath = "CREDITS"
+	}
+	if a.ModTime.IsZero() {
+		a.ModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+		if e, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+			a.ModTime = time.Unix(e, 0).UTC()
+		}
+	}
+	if a.Checksums == "" {
+		a.Checksums = "checksums.txt"
+	}
+	return a
+}
+
+// archiveEntry is the file that is added into the archive.
+type archiveEntry struct {
+	name string // name in the archive.
+	src  string
+	mode os.FileMode
+}
+
+// archiveWriter writes entries in the same way for tar.gz and zip.
+type archiveWriter interface {
+	// add adds the regular file.
+	add(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error
+	Close() error
+}
+
+type tarGzWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzWriter(w io.Writer) *tarGzWriter {
+	gw := gzip.NewWriter(w) // Name, ModTime は空のまま(deterministic).
+	return &tarGzWriter{gw: gw, tw: tar.NewWriter(gw)}
+}
+
+func (w *tarGzWriter) add(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error {
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		ModTime:  modTime,
+		Size:     size,
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func (w *tarGzWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gw.Close()
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) add(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error {
+	h := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	h.SetMode(mode)
+	f, err := w.zw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// copyTarGz copies the entries of the existing tar.gz except skip.
+func copyTarGz(w *tarGzWriter, name string, skip map[string]bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if skip[path.Clean(h.Name)] {
+			continue
+		}
+		if err := w.tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w.tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// copyZip copies the entries of the existing zip except skip.
+func copyZip(w *zipWriter, name string, skip map[string]bool) error {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if skip[path.Clean(f.Name)] {
+			continue
+		}
+		if err := w.zw.Copy(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArchive creates(or updates) the archive that contains entries.
+// The entries of the existing archive are kept unless they are replaced.
+func writeArchive(name, format string, modTime time.Time, entries []archiveEntry) error {
+	tmp, err := writeArchiveTemp(name, format, modTime, entries)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeArchiveTemp writes the archive into the temporary file in the directory of name,
+// and returns the name of the temporary file. The existing archive(name) is not changed.
+func writeArchiveTemp(name, format string, modTime time.Time, entries []archiveEntry) (tmp string, err error) {
+	out, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+"_*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	skip := map[string]bool{}
+	for _, e := range entries {
+		skip[path.Clean(e.name)] = true
+	}
+	_, statErr := os.Stat(name)
+	exists := statErr == nil
+
+	var w archiveWriter
+	switch format {
+	case ArchiveTarGz:
+		tw := newTarGzWriter(out)
+		if exists {
+			if err := copyTarGz(tw, name, skip); err != nil {
+				return "", wrapf(err, "reading '%s'", name)
+			}
+		}
+		w = tw
+	case ArchiveZip:
+		zw := &zipWriter{zw: zip.NewWriter(out)}
+		if exists {
+			if err := copyZip(zw, name, skip); err != nil {
+				return "", wrapf(err, "reading '%s'", name)
+			}
+		}
+		w = zw
+	default:
+		return "", fmt.Errorf("unknown archive format '%s'", format)
+	}
+
+	for _, e := range entries {
+		if err := func() error {
+			f, err := os.Open(e.src)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			s, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			return w.add(e.name, e.mode, modTime, s.Size(), f)
+		}(); err != nil {
+			return "", wrapf(err, "adding '%s'", e.src)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := out.Chmod(0644); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// writeChecksums writes the checksums of files in the format of sha256sum into the temporary file
+// in the directory of name, and returns the name of the temporary file.
+// The lines of the other files in the existing checksums file are kept.
+func writeChecksums(name string, files []*outputHash) (string, error) {
+	sorted := append([]*outputHash{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].outFileName < sorted[j].outFileName })
+	own := map[string]bool{}
+	for _, f := range sorted {
+		own[filepath.Base(f.outFileName)] = true
+	}
+	keep := []string{}
+	in, err := ioutil.ReadFile(name)
+	switch {
+	case err == nil:
+		scanner := bufio.NewScanner(bytes.NewReader(in))
+		for scanner.Scan() {
+			l := scanner.Text()
+			if e := checksumEntry(l); e != "" && own[e] {
+				continue
+			}
+			keep = append(keep, l)
+		}
+		if err := scanner.Err(); err != nil {
+			return "", wrapf(err, "reading '%s'", name)
+		}
+	case os.IsNotExist(err) == false:
+		return "", err
+	}
+	out, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+"_*.tmp")
+	if err != nil {
+		return "", err
+	}
+	err = func() error {
+		for _, l := range keep {
+			if _, err := fmt.Fprintln(out, l); err != nil {
+				return err
+			}
+		}
+		for _, f := range sorted {
+			if _, err := fmt.Fprintf(out, "%x  %s\n", f.hash, filepath.Base(f.outFileName)); err != nil {
+				return err
+			}
+		}
+		if err := out.Chmod(0644); err != nil {
+			return err
+		}
+		return out.Close()
+	}()
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// fileSha256 returns the SHA-256 of the file in fsys.
+func fileSha256(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// archive creates(or updates) the archive of each job, and regenerates the checksums.
+// The archives are written into the temporary files, and they are committed with the other files.
+func (d *baseDist) archive(jobs []*distJob) error {
+	if d.archiveConfig.Format == "" {
+		return nil
+	}
+	a := d.archiveConfig.withDefault(d.outDir)
+	archives := []*outputHash{}
+	for _, j := range jobs {
+		e := j.entry
+		s, err := os.Stat(e.Binary)
+		if err != nil {
+			return newPlatformError(e.Platform(), e.Binary, stageErr(StageArchive, err))
+		}
+		if s.Mode().IsRegular() == false {
+			return newPlatformError(e.Platform(), e.Binary, stageErr(StageArchive, ErrNotGoBinary))
+		}
+		name := filepath.Join(a.Dir, e.Dir+"."+a.Format)
+		entries := []archiveEntry{
+			{name: filepath.Base(e.Binary), src: e.Binary, mode: s.Mode().Perm()},
+		}
+		for i := range d.outputs {
+			n := path.Join(path.Dir(a.CreditsPath), d.outputs[i].BaseName)
+			if i == 0 {
+				// 最初の output(既定では CREDITS)は CreditsPath に置く.
+				n = a.CreditsPath
+			}
+			// CREDITS ファイルはまだ commit されていないので一時ファイルから読む.
+			entries = append(entries, archiveEntry{name: n, src: j.files[i].tmpFileName, mode: 0644})
+		}
+		tmp, err := writeArchiveTemp(name, a.Format, a.ModTime, entries)
+		if err != nil {
+			return newPlatformError(e.Platform(), e.Binary, stageErr(StageArchive, err))
+		}
+		f := &outputHash{outFileName: name, tmpFileName: tmp}
+		d.archives = append(d.archives, f)
+		if f.hash, err = fileSha256(OSFS(), tmp); err != nil {
+			return newPlatformError(e.Platform(), e.Binary, stageErr(StageArchive, err))
+		}
+		archives = append(archives, f)
+	}
+	checksums := filepath.Join(a.Dir, a.Checksums)
+	tmp, err := writeChecksums(checksums, archives)
+	if err != nil {
+		return stageErr(StageArchive, wrapf(err, "writing checksums"))
+	}
+	d.archiveSums = &outputHash{outFileName: checksums, tmpFileName: tmp}
+	return nil
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var archiveExts = []string{".tar.gz", ".tgz", ".zip"}
+
+// archiveBase returns the name of the archive without the extension.
+func archiveBase(name string) (string, bool) {
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+	return "", false
+}
+
+var errStopWalk = errors.New("stop walking")
+
+// walkArchive calls fn for each regular file in the archive(tar.gz or zip).
+// Returning errStopWalk from fn stops walking without error.
+func walkArchive(name string, fn func(entry string, mode os.FileMode, r io.Reader) error) error {
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.OpenReader(name)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.Mode().IsRegular() == false {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(f.Name, f.Mode(), r)
+			r.Close()
+			if err == errStopWalk {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		err = fn(h.Name, h.FileInfo().Mode(), tr)
+		if err == errStopWalk {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readGoExecutable reads r into memory and returns it if r is a Go executable.
+func readGoExecutable(r io.Reader) ([]byte, *buildinfo.BuildInfo, bool) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, false
+	}
+	bi, err := buildinfo.Read(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, false
+	}
+	return b, bi, true
+}
+
+// findBinaryInArchive returns the name of the first Go executable in the archive.
+// It reads the archive in memory(no files are written).
+func findBinaryInArchive(name string) (string, *buildinfo.BuildInfo, error) {
+	entry := ""
+	var bi *buildinfo.BuildInfo
+	err := walkArchive(name, func(e string, mode os.FileMode, r io.Reader) error {
+		if _, b, ok := readGoExecutable(r); ok {
+			entry = e
+			bi = b
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, wrapf(err, "findBinaryInArchive reading '%s'", name)
+	}
+	if entry == "" {
+		return "", nil, fmt.Errorf("findBinaryInArchive '%s': %w", name, ErrNotGoBinary)
+	}
+	return entry, bi, nil
+}
+
+// extractFromArchive extracts the entry of the archive into dst.
+func extractFromArchive(name, entry, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return wrapf(err, "extractFromArchive")
+	}
+	found := false
+	err := walkArchive(name, func(e string, mode os.FileMode, r io.Reader) error {
+		if path.Clean(e) != path.Clean(entry) {
+			return nil
+		}
+		found = true
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		if _, err := io.Copy(out, r); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return wrapf(err, "extractFromArchive '%s'", name)
+	}
+	if found == false {
+		return fmt.Errorf("extractFromArchive '%s': %s is not found", name, entry)
+	}
+	return nil
+}
+
+// platformOfBinary returns os and arch from the build info(ie. linux, amd64).
+// The variants(GOAMD64, GO386 etc.) are not included.
+func platformOfBinary(bi *buildinfo.BuildInfo) (string, string) {
+	var goos, goarch string
+	for _, kv := range bi.Settings {
+		switch kv.Key {
+		case "GOOS":
+			goos = kv.Value
+		case "GOARCH":
+			goarch = kv.Value
+		}
+	}
+	return goos, goarch
+}
+
+// planArchive returns PlanEntry of the archive in DistDir.
+// The binary is extracted into WorkDir by Run(see extract).
+// The platform is taken from the build info of the binary, or the name of the archive.
+func (d *baseDist) planArchive(name string) (PlanEntry, error) {
+	if isOSFS(d.fs) == false {
+		return PlanEntry{}, fmt.Errorf("planArchive '%s': %w", name, ErrUnsupportedFS)
+	}
+	base, _ := archiveBase(name)
+	archive := filepath.Join(d.distDir, name)
+	entry, bi, err := findBinaryInArchive(archive)
+	if err != nil {
+		return PlanEntry{}, err
+	}
+	// build info を優先し、無い場合(go1.18 より前のバイナリ)は名前から取り出す.
+	goos, goarch := platformOfBinary(bi)
+	o, a, named := platformSuffix(base, d.replaceOs, d.replaceArch)
+	o, a = restoreItem(d.replaceOs, o), restoreItem(d.replaceArch, a)
+	switch {
+	case goos == "" || goarch == "":
+		if named == false {
+			return PlanEntry{}, fmt.Errorf("planArchive '%s': the platform is unknown", name)
+		}
+		goos, goarch = o, a
+	case named && o == goos && strings.HasPrefix(a, goarch+"_"):
+		// variant(ie. amd64_v3) は名前に含まれている場合のみ残す.
+		goarch = a
+	}
+	s := []string{goos, goarch}
+	return PlanEntry{
+		Dir:          base,
+		Archive:      archive,
+		ArchiveEntry: entry,
+		Binary:       filepath.Join(d.workDir, "archives", base, path.Base(entry)),
+		Os:           s[0],
+		Arch:         s[1],
+		ReplacedOs:   ReplaceItem(d.replaceOs, s[0]),
+		ReplacedArch: ReplaceItem(d.replaceArch, s[1]),
+	}, nil
+}
+
+// extract extracts the binary of the entry if it is in the archive(or the image).
+func (d *baseDist) extract(e PlanEntry) error {
+	var err error
+	switch {
+	case e.Image != "":
+		var x *tarIndex
+		if x, err = d.imageIndexOf(e.Image); err == nil {
+			err = extractFromImage(x, e.ImageLayer, e.ArchiveEntry, e.Binary)
+		}
+	case e.Archive != "":
+		err = extractFromArchive(e.Archive, e.ArchiveEntry, e.Binary)
+	}
+	if err != nil {
+		return newPlatformError(e.Platform(), e.Binary, stageErr(StageModules, err))
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"debug/buildinfo"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_platformOfBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []debug.BuildSetting
+		wantOs   string
+		wantArch string
+	}{
+		{
+			name:     "basic",
+			settings: []debug.BuildSetting{{Key: "GOOS", Value: "linux"}, {Key: "GOARCH", Value: "386"}},
+			wantOs:   "linux",
+			wantArch: "386",
+		}, {
+			name: "version",
+			settings: []debug.BuildSetting{
+				{Key: "GOOS", Value: "linux"}, {Key: "GOARCH", Value: "amd64"}, {Key: "GOAMD64", Value: "v1"},
+			},
+			wantOs:   "linux",
+			wantArch: "amd64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOs, gotArch := platformOfBinary(&buildinfo.BuildInfo{Settings: tt.settings})
+			assert.Equal(t, tt.wantOs, gotOs, "os")
+			assert.Equal(t, tt.wantArch, gotArch, "arch")
+		})
+	}
+}
+
+func Test_baseDist_Run_With_Archives(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "work_distArchives")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+	modTime := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	err = ResetDir(outDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(outDir)
+
+	err = ResetDir(distDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(distDir)
+	for _, a := range []struct {
+		name    string
+		format  string
+		entries []archiveEntry
+	}{
+		{
+			name:   "my_cmd_linux_386.tar.gz",
+			format: ArchiveTarGz,
+			entries: []archiveEntry{
+				{name: "README.md", src: filepath.Join(cwd, "README.md"), mode: 0644},
+				{name: "my_cmd", src: filepath.Join(testDir, "distDir", "linux_386", "my_cmd"), mode: 0755},
+			},
+		}, {
+			name:   "my_cmd_linux_amd64.zip",
+			format: ArchiveZip,
+			entries: []archiveEntry{
+				{name: "bin/my_cmd", src: filepath.Join(testDir, "distDir", "linux_amd64", "my_cmd"), mode: 0755},
+			},
+		}, {
+			name:   "my_cmd_source.tar.gz",
+			format: ArchiveTarGz,
+			entries: []archiveEntry{
+				{name: "README.md", src: filepath.Join(cwd, "README.md"), mode: 0644},
+			},
+		},
+	} {
+		err := writeArchive(filepath.Join(distDir, a.name), a.format, modTime, a.entries)
+		assert.Nil(t, err, "check")
+	}
+
+	d := NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		Uniq(false).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					_, err := io.Copy(outStream, strings.NewReader("test"))
+					return err
+				}),
+		).
+		Build()
+
+	p, err := d.(Planner).Plan()
+	assert.Nil(t, err, "baseDist.Plan()")
+	assert.Equal(t, Plan{
+		{
+			Dir:          "my_cmd_linux_386",
+			Binary:       filepath.Join(workDir, "archives", "my_cmd_linux_386", "my_cmd"),
+			Archive:      filepath.Join(distDir, "my_cmd_linux_386.tar.gz"),
+			ArchiveEntry: "my_cmd",
+			Os:           "linux",
+			Arch:         "386",
+			ReplacedOs:   "linux",
+			ReplacedArch: "386",
+			OutFile:      filepath.Join(outDir, "CREDITS_linux_386"),
+		}, {
+			Dir:          "my_cmd_linux_amd64",
+			Binary:       filepath.Join(workDir, "archives", "my_cmd_linux_amd64", "my_cmd"),
+			Archive:      filepath.Join(distDir, "my_cmd_linux_amd64.zip"),
+			ArchiveEntry: "bin/my_cmd",
+			Os:           "linux",
+			Arch:         "amd64",
+			ReplacedOs:   "linux",
+			ReplacedArch: "amd64",
+			OutFile:      filepath.Join(outDir, "CREDITS_linux_amd64"),
+		},
+	}, p, "baseDist.Plan()")
+	_, err = os.Stat(filepath.Join(workDir, "archives"))
+	assert.True(t, os.IsNotExist(err), "Plan does not extract")
+
+	err = d.Run()
+	assert.Nil(t, err, "baseDist.Run()")
+	files, err := ioutil.ReadDir(outDir)
+	assert.Nil(t, err, "check")
+	gotFileNames := make([]string, len(files))
+	for i, f := range files {
+		gotFileNames[i] = f.Name()
+	}
+	assert.ElementsMatch(t, []string{"CREDITS_linux_386", "CREDITS_linux_amd64"}, gotFileNames, "files")
+}
+
+func Test_baseDist_planArchive(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "work_distArchives")
+	workDir := filepath.Join(testDir, "work_dist")
+	modTime := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	err = ResetDir(distDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(distDir)
+
+	d := NewDistBuilder().
+		DistDir(distDir).
+		WorkDir(workDir).
+		ReplaceOs([][]string{{"linux", "Linux"}}).
+		ReplaceArch([][]string{{"amd64", "x86_64"}}).
+		Build().(*baseDist)
+	tests := []struct {
+		name    string
+		want    PlanEntry
+		wantErr bool
+	}{
+		{
+			name: "my_cmd_1.0.0_Linux_x86_64.tar.gz",
+			want: PlanEntry{
+				Dir:          "my_cmd_1.0.0_Linux_x86_64",
+				Binary:       filepath.Join(workDir, "archives", "my_cmd_1.0.0_Linux_x86_64", "my_cmd"),
+				Archive:      filepath.Join(distDir, "my_cmd_1.0.0_Linux_x86_64.tar.gz"),
+				ArchiveEntry: "my_cmd",
+				Os:           "linux",
+				Arch:         "amd64",
+				ReplacedOs:   "Linux",
+				ReplacedArch: "x86_64",
+			},
+		},
+		{name: "tool_v1.tar.gz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := writeArchive(filepath.Join(distDir, tt.name), ArchiveTarGz, modTime, []archiveEntry{
+				{name: "my_cmd", src: filepath.Join(testDir, "distDir", "linux_amd64", "my_cmd"), mode: 0755},
+			})
+			assert.Nil(t, err, "check")
+			got, err := d.planArchive(tt.name)
+			if tt.wantErr {
+				assert.NotNil(t, err, "baseDist.planArchive()")
+				return
+			}
+			assert.Nil(t, err, "baseDist.planArchive()")
+			assert.Equal(t, tt.want, got, "baseDist.planArchive()")
+		})
+	}
+}
+
+func Test_baseDist_planArchive_BuildInfo(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "work_distArchives")
+	workDir := filepath.Join(testDir, "work_dist")
+	srcDir := filepath.Join(testDir, "work_src")
+	modTime := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	err = ResetDir(distDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(distDir)
+
+	// go1.18 以降のバイナリは build info に GOAMD64 などが含まれる.
+	err = ResetDir(srcDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(srcDir)
+	err = ioutil.WriteFile(filepath.Join(srcDir, "go.mod"), []byte("module example.com/my_cmd\n\ngo 1.21\n"), 0644)
+	assert.Nil(t, err, "check")
+	err = ioutil.WriteFile(filepath.Join(srcDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	assert.Nil(t, err, "check")
+	build := func(name string, env ...string) string {
+		bin := filepath.Join(srcDir, name)
+		cmd := exec.Command("go", "build", "-o", bin, ".")
+		cmd.Dir = srcDir
+		cmd.Env = append(append(os.Environ(), "CGO_ENABLED=0", "GOFLAGS=-mod=mod"), env...)
+		out, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(out))
+		return bin
+	}
+	amd64V1 := build("amd64_v1", "GOOS=linux", "GOARCH=amd64", "GOAMD64=v1")
+	amd64V3 := build("amd64_v3", "GOOS=linux", "GOARCH=amd64", "GOAMD64=v3")
+	i386 := build("386", "GOOS=linux", "GOARCH=386", "GO386=sse2")
+
+	d := NewDistBuilder().
+		DistDir(distDir).
+		WorkDir(workDir).
+		ReplaceArch([][]string{{"amd64", "x86_64"}, {"386", "i386"}}).
+		Build().(*baseDist)
+	tests := []struct {
+		name string
+		bin  string
+		want PlanEntry
+	}{
+		{
+			name: "my_cmd_linux_x86_64.tar.gz",
+			bin:  amd64V1,
+			want: PlanEntry{
+				Dir:          "my_cmd_linux_x86_64",
+				Binary:       filepath.Join(workDir, "archives", "my_cmd_linux_x86_64", "my_cmd"),
+				Archive:      filepath.Join(distDir, "my_cmd_linux_x86_64.tar.gz"),
+				ArchiveEntry: "my_cmd",
+				Os:           "linux",
+				Arch:         "amd64",
+				ReplacedOs:   "linux",
+				ReplacedArch: "x86_64",
+			},
+		}, {
+			name: "my_cmd_linux_i386.tar.gz",
+			bin:  i386,
+			want: PlanEntry{
+				Dir:          "my_cmd_linux_i386",
+				Binary:       filepath.Join(workDir, "archives", "my_cmd_linux_i386", "my_cmd"),
+				Archive:      filepath.Join(distDir, "my_cmd_linux_i386.tar.gz"),
+				ArchiveEntry: "my_cmd",
+				Os:           "linux",
+				Arch:         "386",
+				ReplacedOs:   "linux",
+				ReplacedArch: "i386",
+			},
+		}, {
+			name: "my_cmd_linux_amd64_v3.tar.gz",
+			bin:  amd64V3,
+			want: PlanEntry{
+				Dir:          "my_cmd_linux_amd64_v3",
+				Binary:       filepath.Join(workDir, "archives", "my_cmd_linux_amd64_v3", "my_cmd"),
+				Archive:      filepath.Join(distDir, "my_cmd_linux_amd64_v3.tar.gz"),
+				ArchiveEntry: "my_cmd",
+				Os:           "linux",
+				Arch:         "amd64_v3",
+				ReplacedOs:   "linux",
+				ReplacedArch: "amd64_v3",
+			},
+		}, {
+			name: "my_cmd.tar.gz",
+			bin:  amd64V3,
+			want: PlanEntry{
+				Dir:          "my_cmd",
+				Binary:       filepath.Join(workDir, "archives", "my_cmd", "my_cmd"),
+				Archive:      filepath.Join(distDir, "my_cmd.tar.gz"),
+				ArchiveEntry: "my_cmd",
+				Os:           "linux",
+				Arch:         "amd64",
+				ReplacedOs:   "linux",
+				ReplacedArch: "x86_64",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := writeArchive(filepath.Join(distDir, tt.name), ArchiveTarGz, modTime, []archiveEntry{
+				{name: "my_cmd", src: tt.bin, mode: 0755},
+			})
+			assert.Nil(t, err, "check")
+			got, err := d.planArchive(tt.name)
+			assert.Nil(t, err, "baseDist.planArchive()")
+			assert.Equal(t, tt.want, got, "baseDist.planArchive()")
+		})
+	}
+}
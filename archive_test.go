@@ -0,0 +1,233 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testArchiveEntry struct {
+	mode    os.FileMode
+	modTime time.Time
+	content string
+}
+
+func readTestArchive(t *testing.T, name, format string) map[string]testArchiveEntry {
+	ret := map[string]testArchiveEntry{}
+	switch format {
+	case ArchiveTarGz:
+		f, err := os.Open(name)
+		assert.Nil(t, err, "check")
+		defer f.Close()
+		gr, err := gzip.NewReader(f)
+		assert.Nil(t, err, "check")
+		tr := tar.NewReader(gr)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err, "check")
+			b, err := ioutil.ReadAll(tr)
+			assert.Nil(t, err, "check")
+			ret[h.Name] = testArchiveEntry{mode: os.FileMode(h.Mode), modTime: h.ModTime.UTC(), content: string(b)}
+		}
+	case ArchiveZip:
+		zr, err := zip.OpenReader(name)
+		assert.Nil(t, err, "check")
+		defer zr.Close()
+		for _, f := range zr.File {
+			r, err := f.Open()
+			assert.Nil(t, err, "check")
+			b, err := ioutil.ReadAll(r)
+			assert.Nil(t, err, "check")
+			r.Close()
+			ret[f.Name] = testArchiveEntry{mode: f.Mode().Perm(), modTime: f.Modified.UTC(), content: string(b)}
+		}
+	}
+	return ret
+}
+
+func Test_baseDist_Run_With_Archive(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+	modTime := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		format string
+	}{
+		{name: "tar.gz", format: ArchiveTarGz},
+		{name: "zip", format: ArchiveZip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			err = ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(outDir)
+
+			// 既存のアーカイブを更新する.
+			err = writeArchive(filepath.Join(outDir, "linux_386."+tt.format), tt.format, modTime, []archiveEntry{
+				{name: "README.md", src: filepath.Join(cwd, "README.md"), mode: 0644},
+				{name: "doc/CREDITS", src: filepath.Join(cwd, "LICENSE"), mode: 0644},
+			})
+			assert.Nil(t, err, "check")
+			// アーカイブ以外の行は残す.
+			other := "0123456789abcdef  other.txt\n"
+			err = ioutil.WriteFile(filepath.Join(outDir, "checksums.txt"), []byte(other+"0123456789abcdef  linux_386."+tt.format+"\n"), 0644)
+			assert.Nil(t, err, "check")
+
+			d := NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				WorkDir(workDir).
+				Outputs([]DistOutput{
+					{Name: "credits", BaseName: "CREDITS"},
+					{Name: "json", BaseName: "CREDITS.json", Render: func(w io.Writer, in RenderInput) error {
+						_, err := io.WriteString(w, "json")
+						return err
+					}},
+				}).
+				Archive(ArchiveConfig{
+					Format:      tt.format,
+					CreditsPath: "doc/CREDITS",
+					ModTime:     modTime,
+				}).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							_, err := io.Copy(outStream, strings.NewReader("test"))
+							return err
+						}),
+				).
+				Build()
+			err = d.Run()
+			assert.Nil(t, err, "baseDist.Run()")
+
+			readme, err := ioutil.ReadFile(filepath.Join(cwd, "README.md"))
+			assert.Nil(t, err, "check")
+			sums := []string{}
+			for _, p := range []string{"linux_386", "linux_amd64", "linux_amd64_v1"} {
+				name := filepath.Join(outDir, p+"."+tt.format)
+				bin, err := ioutil.ReadFile(filepath.Join(distDir, p, "my_cmd"))
+				assert.Nil(t, err, "check")
+				// バイナリのモードは元のファイルのモード.
+				binStat, err := os.Stat(filepath.Join(distDir, p, "my_cmd"))
+				assert.Nil(t, err, "check")
+				want := map[string]testArchiveEntry{
+					"my_cmd":           {mode: binStat.Mode().Perm(), modTime: modTime, content: string(bin)},
+					"doc/CREDITS":      {mode: 0644, modTime: modTime, content: "test"},
+					"doc/CREDITS.json": {mode: 0644, modTime: modTime, content: "json"},
+				}
+				if p == "linux_386" {
+					want["README.md"] = testArchiveEntry{mode: 0644, modTime: modTime, content: string(readme)}
+				}
+				assert.Equal(t, want, readTestArchive(t, name, tt.format), "archive "+p)
+				s, err := os.Stat(name)
+				assert.Nil(t, err, "check")
+				assert.Equal(t, os.FileMode(0644), s.Mode().Perm(), "mode "+p)
+
+				sum, err := fileSha256(OSFS(), name)
+				assert.Nil(t, err, "check")
+				sums = append(sums, fmt.Sprintf("%x  %s\n", sum, filepath.Base(name)))
+			}
+			got, err := ioutil.ReadFile(filepath.Join(outDir, "checksums.txt"))
+			assert.Nil(t, err, "check")
+			assert.Equal(t, other+strings.Join(sums, ""), string(got), "checksums.txt")
+			s, err := os.Stat(filepath.Join(outDir, "checksums.txt"))
+			assert.Nil(t, err, "check")
+			assert.Equal(t, os.FileMode(0644), s.Mode().Perm(), "mode checksums.txt")
+
+			// deterministic.
+			prev, err := ioutil.ReadFile(filepath.Join(outDir, "linux_amd64."+tt.format))
+			assert.Nil(t, err, "check")
+			err = os.Remove(filepath.Join(outDir, "linux_amd64."+tt.format))
+			assert.Nil(t, err, "check")
+			err = d.Run()
+			assert.Nil(t, err, "baseDist.Run()")
+			b, err := ioutil.ReadFile(filepath.Join(outDir, "linux_amd64."+tt.format))
+			assert.Nil(t, err, "check")
+			assert.True(t, bytes.Equal(prev, b), "deterministic")
+		})
+	}
+}
+
+func Test_baseDist_Run_With_Archive_Error(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	err = ResetDir(outDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(outDir)
+
+	// 壊れたアーカイブは更新できないので、CREDITS ファイルも書き出さない.
+	err = ioutil.WriteFile(filepath.Join(outDir, "linux_amd64.tar.gz"), []byte("broken"), 0644)
+	assert.Nil(t, err, "check")
+	err = ioutil.WriteFile(filepath.Join(outDir, "CREDITS"), []byte("prev"), 0644)
+	assert.Nil(t, err, "check")
+
+	err = NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		Archive(ArchiveConfig{Format: ArchiveTarGz}).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					_, err := io.Copy(outStream, strings.NewReader("test"))
+					return err
+				}),
+		).
+		Build().
+		Run()
+	var p *PlatformError
+	assert.True(t, errors.As(err, &p), "PlatformError")
+	assert.Equal(t, StageArchive, p.Stage, "stage")
+
+	files, err := ioutil.ReadDir(outDir)
+	assert.Nil(t, err, "check")
+	got := map[string]string{}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(outDir, f.Name()))
+		assert.Nil(t, err, "check")
+		got[f.Name()] = string(b)
+	}
+	assert.Equal(t, map[string]string{
+		"CREDITS":            "prev",
+		"linux_amd64.tar.gz": "broken",
+	}, got, "files")
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+const (
+	// StatementType is the type of in-toto Statement v1.
+	StatementType = "https://in-toto.io/Statement/v1"
+	// CreditsPredicateType is the predicate type of the statement written by Dist.
+	CreditsPredicateType = "https://github.com/hankei6km/go-ac/credits/v1"
+)
+
+// ResourceDescriptor is the resource descriptor of in-toto(name and digest only).
+type ResourceDescriptor struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Statement is the unsigned in-toto Statement v1 that links the binary to its CREDITS files.
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     CreditsPredicate     `json:"predicate"`
+}
+
+// CreditsPredicate is the predicate of Statement.
+type CreditsPredicate struct {
+	Builder   Generator            `json:"builder"`
+	Generator Generator            `json:"generator"`
+	Platform  string               `json:"platform"`
+	Modules   []Module             `json:"modules"`
+	Files     []ResourceDescriptor `json:"files"`
+}
+
+// Generator is the identity of the tool.
+type Generator struct {
+	ID      string `json:"id"`
+	Version string `json:"version,omitempty"`
+}
+
+// acVersion returns the version of go-ac in the build info.
+func acVersion() string {
+	const p = "github.com/hankei6km/go-ac"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if bi.Main.Path == p {
+			return bi.Main.Version
+		}
+		for _, d := range bi.Deps {
+			if d.Path == p {
+				return d.Version
+			}
+		}
+	}
+	return ""
+}
+
+func sha256Digest(sum []byte) map[string]string {
+	return map[string]string{"sha256": hex.EncodeToString(sum)}
+}
+
+// statementFileName returns the name of the statement of the job(ie. CREDITS.linux_amd64.intoto.json).
+// It is not named like the CREDITS files(CREDITS_*), so that it is not read as the CREDITS file of the platform.
+func (d *baseDist) statementFileName(e PlanEntry) string {
+	return filepath.Join(d.outDir, d.baseName+"."+d.suffixOf(e)+".intoto.json")
+}
+
+// statementSuffix returns the suffix(platform) of name that is returned by statementFileName.
+func (d *baseDist) statementSuffix(name string) (string, bool) {
+	prefix, ext := d.baseName+".", ".intoto.json"
+	if len(name) <= len(prefix)+len(ext) || strings.HasPrefix(name, prefix) == false || strings.HasSuffix(name, ext) == false {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(ext)], true
+}
+
+// generatorOf returns Generator of the generator id(ie. github.com/Songmu/gocredits@v0.3.0).
+// The version is set only if the id has it.
+func generatorOf(id string) Generator {
+	if i := strings.LastIndex(id, "@"); i > 0 {
+		v := id[i+1:]
+		if strings.HasPrefix(v, "v") && strings.ContainsAny(v, "/\\( ") == false {
+			return Generator{ID: id[:i], Version: v}
+		}
+	}
+	return Generator{ID: id}
+}
+
+// statement returns the statement of the job.
+func (d *baseDist) statement(j *distJob) (Statement, error) {
+	sum, err := fileSha256(d.fs, j.entry.Binary)
+	if err != nil {
+		return Statement{}, err
+	}
+	mods := append([]Module{}, j.o.resolved()...)
+	sort.Slice(mods, func(i, k int) bool { return mods[i].Path < mods[k].Path })
+	files := make([]ResourceDescriptor, len(j.files))
+	for i, f := range j.files {
+		files[i] = ResourceDescriptor{Name: filepath.Base(f.outFileName), Digest: sha256Digest(f.hash)}
+	}
+	return Statement{
+		Type: StatementType,
+		Subject: []ResourceDescriptor{
+			{Name: filepath.Base(j.entry.Binary), Digest: sha256Digest(sum)},
+		},
+		PredicateType: CreditsPredicateType,
+		Predicate: CreditsPredicate{
+			Builder:   Generator{ID: "github.com/hankei6km/go-ac", Version: acVersion()},
+			Generator: generatorOf(j.generator),
+			Platform:  j.entry.Platform(),
+			Modules:   mods,
+			Files:     files,
+		},
+	}, nil
+}
+
+// writeStatements writes the statement of each job into the sidecar files.
+// They are not signed, sign them by another tool.
+func (d *baseDist) writeStatements(jobs []*distJob) error {
+	if d.attestations == false {
+		return nil
+	}
+	for _, j := range jobs {
+		s, err := d.statement(j)
+		if err != nil {
+			return newPlatformError(j.entry.Platform(), j.entry.Binary, wrapf(err, "writeStatements"))
+		}
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return wrapf(err, "writeStatements encoding")
+		}
+		f, err := d.writeTempFile(d.statementFileName(j.entry), append(b, '\n'))
+		if err != nil {
+			return wrapf(err, "writeStatements")
+		}
+		d.sidecar = append(d.sidecar, f)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_baseDist_Run_Attestations(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	sum := func(name string) map[string]string {
+		b, err := ioutil.ReadFile(name)
+		assert.Nil(t, err, "check")
+		return map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256(b))}
+	}
+
+	tests := []struct {
+		name      string
+		uniq      bool
+		platforms []string
+		wantFiles func(platform string) []string
+	}{
+		{
+			name:      "uniq",
+			uniq:      true,
+			platforms: []string{"linux_386", "linux_amd64", "linux_amd64_v1"},
+			wantFiles: func(platform string) []string { return []string{"CREDITS"} },
+		}, {
+			name:      "platforms",
+			uniq:      false,
+			platforms: []string{"linux_386", "linux_amd64", "linux_amd64_v1"},
+			wantFiles: func(platform string) []string { return []string{"CREDITS_" + platform} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(outDir)
+
+			err = NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				WorkDir(workDir).
+				Uniq(tt.uniq).
+				Attestations(true).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							// LoadSnapshot で読めるように CREDITS の形式で書き出す.
+							return writeLicense(outStream, "gopkg.in/yaml.v2", "https://gopkg.in/yaml.v2", "test")
+						}),
+				).
+				Build().
+				Run()
+			assert.Nil(t, err, "baseDist.Run()")
+
+			for _, p := range tt.platforms {
+				b, err := ioutil.ReadFile(filepath.Join(outDir, "CREDITS."+p+".intoto.json"))
+				assert.Nil(t, err, "statement")
+				s := Statement{}
+				err = json.Unmarshal(b, &s)
+				assert.Nil(t, err, "statement")
+
+				assert.Equal(t, StatementType, s.Type, "type")
+				assert.Equal(t, CreditsPredicateType, s.PredicateType, "predicateType")
+				assert.Equal(t, []ResourceDescriptor{
+					{Name: "my_cmd", Digest: sum(filepath.Join(distDir, p, "my_cmd"))},
+				}, s.Subject, "subject")
+				assert.Equal(t, p, s.Predicate.Platform, "platform")
+				assert.Equal(t, []Module{{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"}}, s.Predicate.Modules, "modules")
+				assert.Equal(t, generatorOf(gocreditsID()), s.Predicate.Generator, "generator")
+				assert.NotEmpty(t, s.Predicate.Generator.Version, "generator version")
+				assert.Equal(t, "github.com/hankei6km/go-ac", s.Predicate.Builder.ID, "builder")
+				files := []ResourceDescriptor{}
+				for _, f := range tt.wantFiles(p) {
+					files = append(files, ResourceDescriptor{Name: f, Digest: sum(filepath.Join(outDir, f))})
+				}
+				assert.Equal(t, files, s.Predicate.Files, "files")
+			}
+
+			snap, err := LoadSnapshot(outDir, "CREDITS")
+			assert.Nil(t, err, "LoadSnapshot()")
+			got := []string{}
+			for p := range snap {
+				got = append(got, p)
+			}
+			want := tt.platforms
+			if tt.uniq {
+				want = []string{""}
+			}
+			assert.ElementsMatch(t, want, got, "LoadSnapshot() platforms")
+		})
+	}
+}
+
+func Test_generatorOf(t *testing.T) {
+	tests := []struct {
+		id   string
+		want Generator
+	}{
+		{id: "github.com/Songmu/gocredits@v0.3.0", want: Generator{ID: "github.com/Songmu/gocredits", Version: "v0.3.0"}},
+		{id: "github.com/Songmu/gocredits", want: Generator{ID: "github.com/Songmu/gocredits"}},
+		{id: "vendor:/go/pkg/mod/example.com/foo@v1.0.0/vendor", want: Generator{ID: "vendor:/go/pkg/mod/example.com/foo@v1.0.0/vendor"}},
+		{id: "/bin/prog@v1(12 34)", want: Generator{ID: "/bin/prog@v1(12 34)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, tt.want, generatorOf(tt.id), "generatorOf()")
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cache is the on-disk cache of the output of the generator.
+// The key is the hash of the module list, the pruned go.sum and the generator identity.
+type Cache struct {
+	fs  WritableFS
+	dir string
+
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+// CacheStats is the statistics of Cache.
+type CacheStats struct {
+	Hits   int
+	Misses int
+	Writes int
+}
+
+// NewCache returns the instance of Cache that stores the entries in dir.
+func NewCache(dir string) *Cache {
+	return NewCacheFS(OSFS(), dir)
+}
+
+// NewCacheFS returns the instance of Cache that stores the entries in dir of fsys.
+func NewCacheFS(fsys WritableFS, dir string) *Cache {
+	return &Cache{fs: fsys, dir: dir}
+}
+
+// Dir returns the directory of the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) fileName(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// Get returns the cached bytes.
+func (c *Cache) Get(key string) ([]byte, bool, error) {
+	b, err := fs.ReadFile(c.fs, c.fileName(key))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.stats.Misses++
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, wrapf(err, "Cache.Get")
+	}
+	c.stats.Hits++
+	return b, true, nil
+}
+
+// Put stores b.
+func (c *Cache) Put(key string, b []byte) error {
+	if err := c.fs.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return wrapf(err, "Cache.Put")
+	}
+	tmp, err := writeTempFS(c.fs, c.dir, ".tmp_*", b, 0600)
+	if err != nil {
+		return wrapf(err, "Cache.Put")
+	}
+	if err := c.fs.Rename(tmp, c.fileName(key)); err != nil {
+		c.fs.Remove(tmp)
+		return wrapf(err, "Cache.Put")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Writes++
+	return nil
+}
+
+// Invalidate removes the entry of key.
+func (c *Cache) Invalidate(key string) error {
+	if err := c.fs.Remove(c.fileName(key)); err != nil && errors.Is(err, fs.ErrNotExist) == false {
+		return wrapf(err, "Cache.Invalidate")
+	}
+	return nil
+}
+
+// Clear removes all entries.
+func (c *Cache) Clear() error {
+	if err := removeAllFS(c.fs, c.dir); err != nil {
+		return wrapf(err, "Cache.Clear")
+	}
+	return nil
+}
+
+// Stats returns the statistics.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// CacheKey returns the key of the cache.
+// The key is the hash of the sorted module path@version list, the go.sum lines and the generator identity.
+func CacheKey(mods []Module, goSum []byte, generatorID string) string {
+	l := make([]string, len(mods))
+	for i, m := range mods {
+		l[i] = m.Path + "@" + m.Version
+	}
+	sort.Strings(l)
+	s := strings.Split(strings.TrimSpace(string(goSum)), "\n")
+	sort.Strings(s)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "generator %s\n", generatorID)
+	for _, m := range l {
+		fmt.Fprintf(h, "module %s\n", m)
+	}
+	for _, g := range s {
+		fmt.Fprintf(h, "go.sum %s\n", g)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// gocreditsID returns the identity of gocredits.Run.
+func gocreditsID() string {
+	const p = "github.com/Songmu/gocredits"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, d := range bi.Deps {
+			if d.Path == p {
+				return p + "@" + d.Version
+			}
+		}
+	}
+	return p
+}
+
+// progID returns the identity of the external program.
+func progID(prog string) string {
+	if s, err := os.Stat(prog); err == nil {
+		return fmt.Sprintf("%s(%d %d)", prog, s.Size(), s.ModTime().UnixNano())
+	}
+	return prog
+}
+
+// generate writes the output of run into w.
+// If the cache is enabled, it is read from(or stored into) the cache.
+func (c *baseOutput) generate(w io.Writer, generatorID string, run func(io.Writer) error) error {
+	c.generatorID = generatorID
+	if c.cache == nil {
+		return run(w)
+	}
+	goSum, err := fs.ReadFile(c.fs, filepath.Join(c.workDir, "go.sum"))
+	if err != nil {
+		return wrapf(err, "generate reading go.sum")
+	}
+	key := CacheKey(c.generated, goSum, generatorID)
+	b, ok, err := c.cache.Get(key)
+	if err != nil {
+		return err
+	}
+	if ok {
+		_, err := io.Copy(w, bytes.NewReader(b))
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err := run(io.MultiWriter(w, buf)); err != nil {
+		return err
+	}
+	if err := c.cache.Put(key, buf.Bytes()); err != nil {
+		// キャッシュに書き込めなくても出力は正しいので警告のみ.
+		fmt.Fprintf(c.errStream, "%v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheKey(t *testing.T) {
+	mods := []Module{
+		{Path: "example.com/foo", Version: "v1.0.0"},
+		{Path: "example.com/bar", Version: "v0.1.0"},
+	}
+	goSum := []byte("example.com/foo v1.0.0 h1:foo=\nexample.com/bar v0.1.0 h1:bar=\n")
+	key := CacheKey(mods, goSum, "gen")
+	assert.Equal(t, key,
+		CacheKey([]Module{mods[1], mods[0]}, []byte("example.com/bar v0.1.0 h1:bar=\nexample.com/foo v1.0.0 h1:foo=\n"), "gen"),
+		"sorted")
+	assert.NotEqual(t, key, CacheKey(mods, goSum, "gen2"), "generator")
+	assert.NotEqual(t, key, CacheKey(mods[:1], goSum, "gen"), "modules")
+	assert.NotEqual(t, key, CacheKey(mods, goSum[:20], "gen"), "go.sum")
+}
+
+func TestCache(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	cacheDir := filepath.Join(cwd, "testdata", "work_cache")
+	defer os.RemoveAll(cacheDir)
+
+	tests := []struct {
+		name string
+		c    *Cache
+	}{
+		{name: "OSFS", c: NewCache(cacheDir)},
+		{name: "MemFS", c: NewCacheFS(NewMemFS(), cacheDir)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			_, ok, err := c.Get("foo")
+			assert.Nil(t, err, "Cache.Get()")
+			assert.False(t, ok, "Cache.Get() miss")
+
+			err = c.Put("foo", []byte("test"))
+			assert.Nil(t, err, "Cache.Put()")
+			got, ok, err := c.Get("foo")
+			assert.Nil(t, err, "Cache.Get()")
+			assert.True(t, ok, "Cache.Get() hit")
+			assert.Equal(t, "test", string(got), "Cache.Get()")
+			assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Writes: 1}, c.Stats(), "Cache.Stats()")
+
+			err = c.Invalidate("foo")
+			assert.Nil(t, err, "Cache.Invalidate()")
+			_, ok, _ = c.Get("foo")
+			assert.False(t, ok, "Cache.Get() after Invalidate")
+
+			err = c.Put("bar", []byte("test"))
+			assert.Nil(t, err, "Cache.Put()")
+			err = c.Clear()
+			assert.Nil(t, err, "Cache.Clear()")
+			_, ok, _ = c.Get("bar")
+			assert.False(t, ok, "Cache.Get() after Clear")
+			_, err = fs.Stat(c.fs, cacheDir)
+			assert.ErrorIs(t, err, fs.ErrNotExist, "Cache.Clear() removes the directory")
+		})
+	}
+}
+
+func Test_funcOutput_Flush_With_Cache(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	binFile := filepath.Join(testDir, "binDir", "my_cmd")
+	workDir := filepath.Join(testDir, "work_flush")
+	cacheDir := filepath.Join(testDir, "work_cache")
+	goSumFile := filepath.Join(testDir, "goSum", "go.sum")
+	defer os.RemoveAll(cacheDir)
+
+	cnt := 0
+	c := NewCache(cacheDir)
+	b := NewOutputBuilder().
+		WorkDir(workDir).
+		Binary(binFile).
+		GoSumFile(goSumFile).
+		Cache(c).
+		runFunc(func(argv []string, outStream, errStream io.Writer) error {
+			cnt++
+			_, err := io.Copy(outStream, strings.NewReader(fmt.Sprintf("test %d", cnt)))
+			return err
+		})
+
+	hashes := []string{}
+	for i := 0; i < 2; i++ {
+		err := ResetDir(workDir, os.ModePerm)
+		assert.Nil(t, err, "check")
+		defer os.RemoveAll(workDir)
+
+		got := &strings.Builder{}
+		hash, err := b.OutStream(got).Build().Flush()
+		assert.Nil(t, err, "funcOutput.Flush()")
+		assert.Equal(t, "test 1", got.String(), "funcOutput.Flush() outStream")
+		hashes = append(hashes, fmt.Sprintf("%x", hash))
+	}
+	assert.Equal(t, 1, cnt, "generator runs once")
+	assert.Equal(t, hashes[0], hashes[1], "hash")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Writes: 1}, c.Stats(), "Cache.Stats()")
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checksumsName returns the name of the checksum manifest(ie. CREDITS.sha256).
+func (d *baseDist) checksumsName() string {
+	return d.baseName + ".sha256"
+}
+
+// checksumLine returns the line of f in the format of sha256sum.
+// The path is relative to the directory of the manifest.
+func checksumLine(manifest string, f *outputHash) string {
+	name := filepath.Base(f.outFileName)
+	if rel, err := filepath.Rel(filepath.Dir(manifest), f.outFileName); err == nil {
+		name = filepath.ToSlash(rel)
+	}
+	return fmt.Sprintf("%x  %s\n", f.hash, name)
+}
+
+// checksumEntry returns the file name of the line in the format of sha256sum(or goreleaser).
+func checksumEntry(l string) string {
+	t := strings.SplitN(l, " ", 2)
+	if len(t) < 2 {
+		return ""
+	}
+	// "<sum>  <name>" または "<sum> *<name>"(binary mode).
+	return strings.TrimPrefix(strings.TrimPrefix(t[1], " "), "*")
+}
+
+// isDistFile reports whether the file(relative to the directory of the manifest) is
+// the file that is written by Dist.
+func (d *baseDist) isDistFile(manifest, name string) bool {
+	p := filepath.Join(filepath.Dir(manifest), filepath.FromSlash(name))
+	if filepath.Dir(p) != filepath.Clean(d.outDir) {
+		return false
+	}
+	patterns := []string{d.baseName + ".excluded.json", d.checksumsName(), d.baseName + ".*.intoto.json"}
+	for _, o := range d.outputs {
+		patterns = append(patterns, o.BaseName, o.filePattern())
+	}
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, filepath.Base(p)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeChecksumManifest writes the checksums of the files written by Dist(including the merged file and the archives)
+// into <BaseName>.sha256, or updates the lines of them in the existing checksums file(AppendChecksums).
+// It runs after the files are committed and archived.
+func (d *baseDist) writeChecksumManifest() error {
+	if d.checksums == false && d.appendChecksums == "" {
+		return nil
+	}
+	manifest := filepath.Join(d.outDir, d.checksumsName())
+	files := append(append(append([]*outputHash{}, d.hash...), d.sidecar...), d.archives...)
+	sort.Slice(files, func(i, j int) bool { return files[i].outFileName < files[j].outFileName })
+	own := map[string]bool{}
+	for _, f := range files {
+		own[filepath.Clean(f.outFileName)] = true
+	}
+	b := &bytes.Buffer{}
+	if d.appendChecksums != "" {
+		manifest = d.appendChecksums
+		in, err := fs.ReadFile(d.fs, manifest)
+		switch {
+		case err == nil:
+			// 前回の実行で書き出したファイルの行は入れ替える.
+			scanner := bufio.NewScanner(bytes.NewReader(in))
+			for scanner.Scan() {
+				l := scanner.Text()
+				if e := checksumEntry(l); e != "" {
+					if d.isDistFile(manifest, e) || own[filepath.Join(filepath.Dir(manifest), filepath.FromSlash(e))] {
+						continue
+					}
+				}
+				fmt.Fprintln(b, l)
+			}
+			if err := scanner.Err(); err != nil {
+				return wrapf(err, "writeChecksumManifest reading '%s'", manifest)
+			}
+		case os.IsNotExist(err) == false:
+			return wrapf(err, "writeChecksumManifest")
+		}
+	}
+	for _, f := range files {
+		b.WriteString(checksumLine(manifest, f))
+	}
+	f, err := d.writeTempFile(manifest, b.Bytes())
+	if err != nil {
+		return wrapf(err, "writeChecksumManifest")
+	}
+	d.sidecar = append(d.sidecar, f)
+	return d.rename([]*outputHash{f})
+}
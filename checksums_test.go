@@ -0,0 +1,148 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_baseDist_Run_Checksums(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	releaseDir := filepath.Join(testDir, "work_release")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	for _, d := range []string{workDir, releaseDir} {
+		err = ResetDir(d, os.ModePerm)
+		assert.Nil(t, err, "check")
+		defer os.RemoveAll(d)
+	}
+
+	sum := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(outDir, name))
+		assert.Nil(t, err, "check")
+		return fmt.Sprintf("%x", sha256.Sum256(b))
+	}
+	releaseSum := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(releaseDir, name))
+		assert.Nil(t, err, "check")
+		return fmt.Sprintf("%x", sha256.Sum256(b))
+	}
+
+	tests := []struct {
+		name     string
+		uniq     bool
+		append   string
+		archive  bool
+		existing string
+		wantFile string
+		want     func() string
+	}{
+		{
+			name:     "uniq",
+			uniq:     true,
+			wantFile: filepath.Join(outDir, "CREDITS.sha256"),
+			want: func() string {
+				return sum("CREDITS") + "  CREDITS\n"
+			},
+		}, {
+			name:     "platforms",
+			uniq:     false,
+			wantFile: filepath.Join(outDir, "CREDITS.sha256"),
+			want: func() string {
+				return sum("CREDITS_linux_386") + "  CREDITS_linux_386\n" +
+					sum("CREDITS_linux_amd64") + "  CREDITS_linux_amd64\n" +
+					sum("CREDITS_linux_amd64_v1") + "  CREDITS_linux_amd64_v1\n"
+			},
+		}, {
+			name:     "append",
+			uniq:     true,
+			append:   filepath.Join(releaseDir, "checksums.txt"),
+			existing: "0123  my_cmd_linux_386.tar.gz\n4567  ../outDir/CREDITS_linux_386\n89ab *my_cmd_linux_amd64.tar.gz\n",
+			wantFile: filepath.Join(releaseDir, "checksums.txt"),
+			want: func() string {
+				return "0123  my_cmd_linux_386.tar.gz\n89ab *my_cmd_linux_amd64.tar.gz\n" +
+					sum("CREDITS") + "  ../outDir/CREDITS\n"
+			},
+		}, {
+			name:     "append(archives)",
+			uniq:     true,
+			append:   filepath.Join(releaseDir, "checksums.txt"),
+			archive:  true,
+			existing: "0123  my_cmd_source.tar.gz\n4567  ../outDir/CREDITS_linux_386\n",
+			wantFile: filepath.Join(releaseDir, "checksums.txt"),
+			want: func() string {
+				return "0123  my_cmd_source.tar.gz\n" +
+					sum("CREDITS") + "  ../outDir/CREDITS\n" +
+					releaseSum("linux_386.tar.gz") + "  linux_386.tar.gz\n" +
+					releaseSum("linux_amd64.tar.gz") + "  linux_amd64.tar.gz\n" +
+					releaseSum("linux_amd64_v1.tar.gz") + "  linux_amd64_v1.tar.gz\n"
+			},
+		}, {
+			name:     "append(new file)",
+			uniq:     true,
+			append:   filepath.Join(releaseDir, "new.txt"),
+			wantFile: filepath.Join(releaseDir, "new.txt"),
+			want: func() string {
+				return sum("CREDITS") + "  ../outDir/CREDITS\n"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(outDir)
+			if tt.existing != "" {
+				err := ioutil.WriteFile(tt.append, []byte(tt.existing), 0644)
+				assert.Nil(t, err, "check")
+			}
+
+			a := ArchiveConfig{}
+			if tt.archive {
+				a = ArchiveConfig{Format: ArchiveTarGz, Dir: releaseDir}
+			}
+			err = NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				WorkDir(workDir).
+				Uniq(tt.uniq).
+				Checksums(tt.append == "").
+				AppendChecksums(tt.append).
+				Archive(a).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							_, err := io.Copy(outStream, strings.NewReader("test"))
+							return err
+						}),
+				).
+				Build().
+				Run()
+			assert.Nil(t, err, "baseDist.Run()")
+
+			b, err := ioutil.ReadFile(tt.wantFile)
+			assert.Nil(t, err, "check")
+			assert.Equal(t, tt.want(), string(b), "checksums")
+			if tt.append != "" {
+				_, err := os.Stat(filepath.Join(outDir, "CREDITS.sha256"))
+				assert.True(t, os.IsNotExist(err), "CREDITS.sha256 is not written")
+			}
+		})
+	}
+}
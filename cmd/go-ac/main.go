@@ -0,0 +1,82 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+// Command go-ac provides the subcommands of ac.
+//
+//	go-ac diff [-base CREDITS] [-format markdown|json] <old> <new>
+//
+// diff compares the credits of two releases. <old> and <new> are the CREDITS(or JSON) files,
+// or the directories that contain the files of the platforms(ie. CREDITS_linux_amd64).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	ac "github.com/hankei6km/go-ac"
+)
+
+const usage = `usage: go-ac <command> [arguments]
+
+commands:
+  diff [-base CREDITS] [-format markdown|json] <old> <new>
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, outStream, errStream io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(errStream, usage)
+		return 2
+	}
+	switch args[0] {
+	case "diff":
+		return runDiff(args[1:], outStream, errStream)
+	}
+	fmt.Fprintf(errStream, "go-ac: unknown command '%s'\n%s", args[0], usage)
+	return 2
+}
+
+func runDiff(args []string, outStream, errStream io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(errStream)
+	baseName := fs.String("base", "CREDITS", "the base name of the files in the directory")
+	format := fs.String("format", "markdown", "the format of the report(markdown or json)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprint(errStream, usage)
+		return 2
+	}
+	var write func(r *ac.DiffReport, w io.Writer) error
+	switch *format {
+	case "markdown":
+		write = (*ac.DiffReport).WriteMarkdown
+	case "json":
+		write = (*ac.DiffReport).WriteJSON
+	default:
+		fmt.Fprintf(errStream, "go-ac diff: unknown format '%s'\n", *format)
+		return 2
+	}
+
+	old, err := ac.LoadSnapshot(fs.Arg(0), *baseName)
+	if err != nil {
+		fmt.Fprintf(errStream, "go-ac diff: %s\n", err)
+		return 1
+	}
+	new, err := ac.LoadSnapshot(fs.Arg(1), *baseName)
+	if err != nil {
+		fmt.Fprintf(errStream, "go-ac diff: %s\n", err)
+		return 1
+	}
+	if err := write(ac.Diff(old, new), outStream); err != nil {
+		fmt.Fprintf(errStream, "go-ac diff: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_run(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "go-ac-diff")
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	oldFile := filepath.Join(workDir, "old.json")
+	err = ioutil.WriteFile(oldFile, []byte(`[{"path": "github.com/foo/a", "version": "v1.0.0", "license": "MIT"}]`), 0644)
+	assert.Nil(t, err, "check")
+	newFile := filepath.Join(workDir, "new.json")
+	err = ioutil.WriteFile(newFile, []byte(`[{"path": "github.com/foo/a", "version": "v1.1.0", "license": "MIT"}]`), 0644)
+	assert.Nil(t, err, "check")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    string
+		wantErrOut string
+	}{
+		{
+			name:     "markdown",
+			args:     []string{"diff", oldFile, newFile},
+			wantCode: 0,
+			wantOut:  "### Upgraded\n\n- `github.com/foo/a` v1.0.0 -> v1.1.0\n\n",
+		}, {
+			name:     "json",
+			args:     []string{"diff", "-format", "json", oldFile, newFile},
+			wantCode: 0,
+			wantOut:  `"upgraded": [`,
+		}, {
+			name:       "not found",
+			args:       []string{"diff", oldFile, filepath.Join(workDir, "foo")},
+			wantCode:   1,
+			wantErrOut: "go-ac diff: ",
+		}, {
+			name:       "unknown format",
+			args:       []string{"diff", "-format", "html", oldFile, newFile},
+			wantCode:   2,
+			wantErrOut: "unknown format 'html'",
+		}, {
+			name:       "unknown command",
+			args:       []string{"foo"},
+			wantCode:   2,
+			wantErrOut: "unknown command 'foo'",
+		}, {
+			name:       "no args",
+			args:       []string{"diff", oldFile},
+			wantCode:   2,
+			wantErrOut: "usage: ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut := &strings.Builder{}, &strings.Builder{}
+			assert.Equal(t, tt.wantCode, run(tt.args, out, errOut), "run()")
+			assert.Contains(t, out.String(), tt.wantOut, "outStream")
+			assert.Contains(t, errOut.String(), tt.wantErrOut, "errStream")
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+// Package credits is the runtime helper for the CREDITS that is embedded by go-ac(see ac.WriteEmbed).
+//
+//	if *showCredits {
+//		fmt.Print(credits.Text())
+//	}
+package credits
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Entry is the license of the module in CREDITS.
+type Entry struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Content string `json:"content"`
+	// Notice is the content of NOTICE file of the module.
+	Notice string `json:"notice,omitempty"`
+}
+
+// noticePrefix is the prefix of the name of NOTICE entry(see ac.NoticePrefix).
+const noticePrefix = "NOTICE: "
+
+var (
+	mu   sync.RWMutex
+	text string
+)
+
+// Register registers the CREDITS text.
+// It is called from the generated source.
+func Register(b []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+	text = string(b)
+}
+
+// RegisterGzip registers the gzip compressed CREDITS text.
+// It panics if b is not valid(the generated source is broken).
+func RegisterGzip(b []byte) {
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		panic("credits: " + err.Error())
+	}
+	defer gr.Close()
+	t, err := ioutil.ReadAll(gr)
+	if err != nil {
+		panic("credits: " + err.Error())
+	}
+	Register(t)
+}
+
+// Text returns the registered CREDITS text.
+func Text() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return text
+}
+
+var (
+	sepEntry   = "\n" + strings.Repeat("=", 64) + "\n"
+	sepContent = strings.Repeat("-", 64)
+)
+
+// Parse parses the CREDITS text(the format of gocredits).
+// NOTICE entries are merged into the entries of the same module.
+func Parse(s string) []Entry {
+	ret := []Entry{}
+	idx := map[string]int{}
+	for _, b := range strings.Split(s, sepEntry) {
+		b = strings.TrimLeft(b, "\n")
+		if b == "" {
+			continue
+		}
+		l := strings.SplitN(b, "\n", 4)
+		e := Entry{Name: l[0]}
+		if len(l) > 1 {
+			e.URL = l[1]
+		}
+		if len(l) > 3 && l[2] == sepContent {
+			e.Content = l[3]
+		}
+		if n := strings.TrimPrefix(e.Name, noticePrefix); n != e.Name {
+			if i, ok := idx[n]; ok {
+				ret[i].Notice = e.Content
+				continue
+			}
+		}
+		idx[e.Name] = len(ret)
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+// Entries returns the entries of the registered CREDITS.
+func Entries() []Entry {
+	return Parse(Text())
+}
+
+// JSON returns the entries of the registered CREDITS as JSON.
+func JSON() ([]byte, error) {
+	return json.Marshal(Entries())
+}
+
+// Handler returns http.Handler that serves the registered CREDITS.
+// It serves JSON if the query has "format=json" or Accept is "application/json", otherwise plain text.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			b, err := JSON()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(Text()))
+	})
+}
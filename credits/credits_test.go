@@ -0,0 +1,142 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package credits
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCredits = "Go (the standard library)\n" +
+	"https://golang.org/\n" +
+	strings.Repeat("-", 64) + "\n" +
+	"Copyright (c) 2009 The Go Authors.\n\nlicense text\n" +
+	strings.Repeat("=", 64) + "\n\n" +
+	"github.com/foo/bar\n" +
+	"https://github.com/foo/bar\n" +
+	strings.Repeat("-", 64) + "\n" +
+	"SPDX-License-Identifier: MIT\n" +
+	strings.Repeat("=", 64) + "\n\n"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []Entry
+	}{
+		{
+			name: "basic",
+			s:    testCredits,
+			want: []Entry{
+				{
+					Name:    "Go (the standard library)",
+					URL:     "https://golang.org/",
+					Content: "Copyright (c) 2009 The Go Authors.\n\nlicense text",
+				}, {
+					Name:    "github.com/foo/bar",
+					URL:     "https://github.com/foo/bar",
+					Content: "SPDX-License-Identifier: MIT",
+				},
+			},
+		}, {
+			name: "notice",
+			s: testCredits +
+				"NOTICE: github.com/foo/bar\n" +
+				"https://github.com/foo/bar\n" +
+				strings.Repeat("-", 64) + "\n" +
+				"notice text\n" +
+				strings.Repeat("=", 64) + "\n\n" +
+				"NOTICE: github.com/foo/baz\n" +
+				"https://github.com/foo/baz\n" +
+				strings.Repeat("-", 64) + "\n" +
+				"orphan\n" +
+				strings.Repeat("=", 64) + "\n\n",
+			want: []Entry{
+				{
+					Name:    "Go (the standard library)",
+					URL:     "https://golang.org/",
+					Content: "Copyright (c) 2009 The Go Authors.\n\nlicense text",
+				}, {
+					Name:    "github.com/foo/bar",
+					URL:     "https://github.com/foo/bar",
+					Content: "SPDX-License-Identifier: MIT",
+					Notice:  "notice text",
+				}, {
+					Name:    "NOTICE: github.com/foo/baz",
+					URL:     "https://github.com/foo/baz",
+					Content: "orphan",
+				},
+			},
+		}, {
+			name: "empty",
+			s:    "",
+			want: []Entry{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Parse(tt.s), "Parse()")
+		})
+	}
+}
+
+func TestRegisterGzip(t *testing.T) {
+	b := &bytes.Buffer{}
+	gw := gzip.NewWriter(b)
+	gw.Write([]byte(testCredits))
+	gw.Close()
+
+	RegisterGzip(b.Bytes())
+	assert.Equal(t, testCredits, Text(), "Text()")
+	assert.Len(t, Entries(), 2, "Entries()")
+	assert.Panics(t, func() { RegisterGzip([]byte("test")) }, "RegisterGzip()")
+}
+
+func TestHandler(t *testing.T) {
+	Register([]byte(testCredits))
+	tests := []struct {
+		name     string
+		url      string
+		accept   string
+		wantType string
+		wantBody string
+	}{
+		{
+			name:     "text",
+			url:      "/credits",
+			wantType: "text/plain; charset=utf-8",
+			wantBody: testCredits,
+		}, {
+			name:     "query",
+			url:      "/credits?format=json",
+			wantType: "application/json",
+			wantBody: `[{"name":"Go (the standard library)","url":"https://golang.org/","content":"Copyright (c) 2009 The Go Authors.\n\nlicense text"},{"name":"github.com/foo/bar","url":"https://github.com/foo/bar","content":"SPDX-License-Identifier: MIT"}]`,
+		}, {
+			name:     "accept",
+			url:      "/credits",
+			accept:   "application/json",
+			wantType: "application/json",
+			wantBody: `[{"name":"Go (the standard library)","url":"https://golang.org/","content":"Copyright (c) 2009 The Go Authors.\n\nlicense text"},{"name":"github.com/foo/bar","url":"https://github.com/foo/bar","content":"SPDX-License-Identifier: MIT"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			Handler().ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code, "status")
+			assert.Equal(t, tt.wantType, w.Header().Get("Content-Type"), "Content-Type")
+			assert.Equal(t, tt.wantBody, w.Body.String(), "body")
+		})
+	}
+}
@@ -0,0 +1,441 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hankei6km/go-ac/credits"
+)
+
+// Component is the third-party component in the release.
+// Version is empty if it is read from CREDITS(it has no version).
+type Component struct {
+	Path        string `json:"path"`
+	Version     string `json:"version,omitempty"`
+	License     string `json:"license,omitempty"` // SPDX ID.
+	LicenseHash string `json:"licenseHash,omitempty"`
+}
+
+// licenseID returns the SPDX ID, or the hash of the license text.
+func (c Component) licenseID() string {
+	if c.License != "" {
+		return c.License
+	}
+	if c.LicenseHash != "" {
+		return "sha256:" + c.LicenseHash
+	}
+	return ""
+}
+
+// sameLicense reports whether the licenses of a and b are the same.
+// The SPDX IDs are compared if both have them, otherwise the hashes of the texts are compared in full
+// (the shorter one, ie. read from JSON, is compared as the prefix).
+// It returns true if they can not be compared.
+func sameLicense(a, b Component) bool {
+	if a.License != "" && b.License != "" {
+		return a.License == b.License
+	}
+	ha, hb := a.LicenseHash, b.LicenseHash
+	if ha == "" || hb == "" {
+		return true
+	}
+	if len(ha) > len(hb) {
+		ha, hb = hb, ha
+	}
+	return strings.HasPrefix(hb, ha)
+}
+
+// Snapshot is the components of the release per platform.
+// The key is the platform(ie. linux_amd64), it is empty if the release has a single CREDITS.
+type Snapshot map[string][]Component
+
+var reSPDX = regexp.MustCompile(`SPDX-License-Identifier:\s*([^\s]+)`)
+
+func componentOf(name, content string) Component {
+	c := Component{Path: name}
+	if m := reSPDX.FindStringSubmatch(content); m != nil {
+		c.License = m[1]
+	}
+	c.LicenseHash = fmt.Sprintf("%x", sha256.Sum256([]byte(strings.TrimSpace(content))))
+	return c
+}
+
+// ComponentsFromModules returns the components of the modules(no license).
+func ComponentsFromModules(mods []Module) []Component {
+	ret := make([]Component, len(mods))
+	for i, m := range mods {
+		ret[i] = Component{Path: m.Path, Version: m.Version}
+	}
+	return ret
+}
+
+// ParseComponents parses CREDITS(text) or JSON(the list of Component or credits.Entry, or the object written by RenderJSON).
+// It returns the error if the content is not recognised.
+func ParseComponents(b []byte) ([]Component, error) {
+	switch s := strings.TrimSpace(string(b)); {
+	case s == "":
+		return []Component{}, nil
+	case strings.HasPrefix(s, "["):
+		l := []struct {
+			Component
+			Name    string `json:"name"`
+			Content string `json:"content"`
+		}{}
+		if err := json.Unmarshal(b, &l); err != nil {
+			return nil, wrapf(err, "ParseComponents")
+		}
+		ret := make([]Component, len(l))
+		for i, e := range l {
+			if e.Path == "" {
+				// credits.JSON() の形式.
+				ret[i] = componentOf(e.Name, e.Content)
+				continue
+			}
+			ret[i] = e.Component
+		}
+		return ret, nil
+	case strings.HasPrefix(s, "{"):
+		return parseRenderedJSON(b)
+	}
+	entries := credits.Parse(string(b))
+	ret := make([]Component, len(entries))
+	for i, e := range entries {
+		if e.Content == "" {
+			return nil, fmt.Errorf("ParseComponents: '%s' is not the entry of CREDITS", e.Name)
+		}
+		ret[i] = componentOf(e.Name, e.Content)
+	}
+	return ret, nil
+}
+
+// parseRenderedJSON parses the object written by RenderJSON.
+// The versions are taken from modules, and the licenses from credits.
+func parseRenderedJSON(b []byte) ([]Component, error) {
+	v := struct {
+		Modules *[]Module        `json:"modules"`
+		Credits *[]credits.Entry `json:"credits"`
+	}{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, wrapf(err, "ParseComponents")
+	}
+	if v.Modules == nil && v.Credits == nil {
+		return nil, fmt.Errorf("ParseComponents: the object has neither modules nor credits")
+	}
+	licenses := map[string]Component{}
+	names := []string{}
+	if v.Credits != nil {
+		for _, e := range *v.Credits {
+			licenses[e.Name] = componentOf(e.Name, e.Content)
+			names = append(names, e.Name)
+		}
+	}
+	ret := []Component{}
+	seen := map[string]bool{}
+	if v.Modules != nil {
+		for _, m := range *v.Modules {
+			c := licenses[m.Path]
+			c.Path, c.Version = m.Path, m.Version
+			ret = append(ret, c)
+			seen[m.Path] = true
+		}
+	}
+	// modules に無いもの(ie. Go 本体)は credits から.
+	for _, n := range names {
+		if seen[n] == false {
+			ret = append(ret, licenses[n])
+			seen[n] = true
+		}
+	}
+	return ret, nil
+}
+
+// snapshotSuffix returns the platform of name that is the file of baseName for the platform.
+// Both of the default name(baseName_<platform>) and the name of Outputs(the platform is inserted before the extension)
+// are accepted. The other files(ie. CREDITS_linux_amd64.json for CREDITS, the temporary files and the statements) are not.
+func snapshotSuffix(baseName, name string) (string, bool) {
+	for _, o := range []DistOutput{{BaseName: baseName, legacy: true}, {BaseName: baseName}} {
+		p, ok := o.fileSuffix(name)
+		// プラットフォームは拡張子を含まない(ie. linux_amd64, Linux_x86_64, my_cmd_linux_arm64_v8).
+		if ok && strings.Contains(p, ".") == false && strings.Contains(p, "_") {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// LoadSnapshot reads the snapshot from the file(CREDITS or JSON) or the directory.
+// In the directory, baseName and the files of baseName for the platforms are read
+// (ie. CREDITS, CREDITS_linux_amd64, or CREDITS.json, CREDITS_linux_amd64.json).
+func LoadSnapshot(name, baseName string) (Snapshot, error) {
+	return LoadSnapshotFS(OSFS(), name, baseName)
+}
+
+// LoadSnapshotFS reads the snapshot from fsys(ie. the output directory in MemFS), see LoadSnapshot.
+func LoadSnapshotFS(fsys fs.FS, name, baseName string) (Snapshot, error) {
+	s, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, wrapf(err, "LoadSnapshot")
+	}
+	files := map[string]string{}
+	if s.IsDir() {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return nil, wrapf(err, "LoadSnapshot")
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() == false {
+				continue
+			}
+			if e.Name() == baseName {
+				files[""] = filepath.Join(name, e.Name())
+				continue
+			}
+			if p, ok := snapshotSuffix(baseName, e.Name()); ok {
+				files[p] = filepath.Join(name, e.Name())
+			}
+		}
+	} else {
+		files[""] = name
+	}
+	ret := Snapshot{}
+	for p, f := range files {
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, wrapf(err, "LoadSnapshot")
+		}
+		c, err := ParseComponents(b)
+		if err != nil {
+			return nil, wrapf(err, "LoadSnapshot '%s'", f)
+		}
+		ret[p] = c
+	}
+	return ret, nil
+}
+
+// all returns the components in all platforms.
+func (s Snapshot) all() map[string]Component {
+	ret := map[string]Component{}
+	for _, p := range s.platforms() {
+		for _, c := range s[p] {
+			if o, ok := ret[c.Path]; ok && compareVersion(o.Version, c.Version) >= 0 {
+				continue
+			}
+			ret[c.Path] = c
+		}
+	}
+	return ret
+}
+
+func (s Snapshot) platforms() []string {
+	ret := []string{}
+	for p := range s {
+		ret = append(ret, p)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// VersionChange is the component whose version is changed.
+type VersionChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// PlatformDiff is the difference of the components in the platform.
+type PlatformDiff struct {
+	Platform string   `json:"platform"`
+	Status   string   `json:"status,omitempty"` // "added" or "removed" if the platform itself is added(removed).
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+}
+
+// DiffReport is the difference between two releases.
+type DiffReport struct {
+	Added          []Component     `json:"added"`
+	Removed        []Component     `json:"removed"`
+	Upgraded       []VersionChange `json:"upgraded"`
+	Downgraded     []VersionChange `json:"downgraded"`
+	LicenseChanged []VersionChange `json:"licenseChanged"` // Old and New are SPDX IDs(or the hashes of the texts).
+	Platforms      []PlatformDiff  `json:"platforms"`
+}
+
+// Empty returns true if there is no difference.
+func (r *DiffReport) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Upgraded) == 0 &&
+		len(r.Downgraded) == 0 && len(r.LicenseChanged) == 0 && len(r.Platforms) == 0
+}
+
+func sortedPaths(m map[string]Component) []string {
+	ret := []string{}
+	for p := range m {
+		ret = append(ret, p)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Diff compares two releases.
+func Diff(old, new Snapshot) *DiffReport {
+	r := &DiffReport{
+		Added:          []Component{},
+		Removed:        []Component{},
+		Upgraded:       []VersionChange{},
+		Downgraded:     []VersionChange{},
+		LicenseChanged: []VersionChange{},
+		Platforms:      []PlatformDiff{},
+	}
+	o, n := old.all(), new.all()
+	for _, p := range sortedPaths(n) {
+		nc := n[p]
+		oc, ok := o[p]
+		if ok == false {
+			r.Added = append(r.Added, nc)
+			continue
+		}
+		v := VersionChange{Path: p, Old: oc.Version, New: nc.Version}
+		switch c := compareVersion(oc.Version, nc.Version); {
+		case oc.Version == "" || nc.Version == "" || oc.Version == nc.Version:
+		case c < 0:
+			r.Upgraded = append(r.Upgraded, v)
+		case c > 0:
+			r.Downgraded = append(r.Downgraded, v)
+		}
+		if sameLicense(oc, nc) == false {
+			r.LicenseChanged = append(r.LicenseChanged, VersionChange{Path: p, Old: oc.licenseID(), New: nc.licenseID()})
+		}
+	}
+	for _, p := range sortedPaths(o) {
+		if _, ok := n[p]; ok == false {
+			r.Removed = append(r.Removed, o[p])
+		}
+	}
+
+	platforms := map[string]bool{}
+	for _, p := range append(old.platforms(), new.platforms()...) {
+		platforms[p] = true
+	}
+	names := []string{}
+	for p := range platforms {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	for _, p := range names {
+		oc, ook := old[p]
+		nc, nok := new[p]
+		d := PlatformDiff{Platform: p}
+		switch {
+		case ook == false:
+			d.Status = "added"
+		case nok == false:
+			d.Status = "removed"
+		}
+		om, nm := map[string]Component{}, map[string]Component{}
+		for _, c := range oc {
+			om[c.Path] = c
+		}
+		for _, c := range nc {
+			nm[c.Path] = c
+		}
+		for _, c := range sortedPaths(nm) {
+			if _, ok := om[c]; ok == false {
+				d.Added = append(d.Added, c)
+			}
+		}
+		for _, c := range sortedPaths(om) {
+			if _, ok := nm[c]; ok == false {
+				d.Removed = append(d.Removed, c)
+			}
+		}
+		if d.Status != "" || len(d.Added) > 0 || len(d.Removed) > 0 {
+			r.Platforms = append(r.Platforms, d)
+		}
+	}
+	return r
+}
+
+// WriteJSON writes the report as JSON.
+func (r *DiffReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// shortLicenseID shortens the hash of the license text for Markdown.
+func shortLicenseID(id string) string {
+	if h := strings.TrimPrefix(id, "sha256:"); h != id && len(h) > 12 {
+		return "sha256:" + h[:12]
+	}
+	return id
+}
+
+func componentLabel(c Component) string {
+	s := "`" + c.Path + "`"
+	if c.Version != "" {
+		s += " " + c.Version
+	}
+	if l := shortLicenseID(c.licenseID()); l != "" {
+		s += " (" + l + ")"
+	}
+	return s
+}
+
+// WriteMarkdown writes the report as Markdown(for release notes).
+func (r *DiffReport) WriteMarkdown(w io.Writer) error {
+	b := &strings.Builder{}
+	if r.Empty() {
+		b.WriteString("No changes in third-party components.\n")
+	}
+	section := func(title string, n int, fn func()) {
+		if n == 0 {
+			return
+		}
+		fmt.Fprintf(b, "### %s\n\n", title)
+		fn()
+		b.WriteString("\n")
+	}
+	section("Added", len(r.Added), func() {
+		for _, c := range r.Added {
+			fmt.Fprintf(b, "- %s\n", componentLabel(c))
+		}
+	})
+	section("Removed", len(r.Removed), func() {
+		for _, c := range r.Removed {
+			fmt.Fprintf(b, "- %s\n", componentLabel(c))
+		}
+	})
+	section("Upgraded", len(r.Upgraded), func() {
+		for _, v := range r.Upgraded {
+			fmt.Fprintf(b, "- `%s` %s -> %s\n", v.Path, v.Old, v.New)
+		}
+	})
+	section("Downgraded", len(r.Downgraded), func() {
+		for _, v := range r.Downgraded {
+			fmt.Fprintf(b, "- `%s` %s -> %s\n", v.Path, v.Old, v.New)
+		}
+	})
+	section("License changed", len(r.LicenseChanged), func() {
+		for _, v := range r.LicenseChanged {
+			fmt.Fprintf(b, "- `%s` %s -> %s\n", v.Path, shortLicenseID(v.Old), shortLicenseID(v.New))
+		}
+	})
+	section("Platforms", len(r.Platforms), func() {
+		b.WriteString("| Platform | Status | Added | Removed |\n|---|---|---|---|\n")
+		for _, p := range r.Platforms {
+			fmt.Fprintf(b, "| %s | %s | %s | %s |\n", p.Platform, p.Status,
+				strings.Join(p.Added, "<br>"), strings.Join(p.Removed, "<br>"))
+		}
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
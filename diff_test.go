@@ -0,0 +1,280 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  Snapshot
+		new  Snapshot
+		want *DiffReport
+	}{
+		{
+			name: "basic",
+			old: Snapshot{
+				"linux_amd64": {
+					{Path: "github.com/foo/a", Version: "v1.0.0", License: "MIT"},
+					{Path: "github.com/foo/b", Version: "v1.2.0", License: "MIT"},
+					{Path: "github.com/foo/c", Version: "v0.1.0"},
+				},
+				"windows_386": {
+					{Path: "github.com/foo/a", Version: "v1.0.0", License: "MIT"},
+				},
+			},
+			new: Snapshot{
+				"linux_amd64": {
+					{Path: "github.com/foo/a", Version: "v1.1.0", License: "Apache-2.0"},
+					{Path: "github.com/foo/b", Version: "v1.1.0", License: "MIT"},
+					{Path: "github.com/foo/d", Version: "v0.2.0"},
+				},
+				"darwin_arm64": {
+					{Path: "github.com/foo/a", Version: "v1.1.0", License: "Apache-2.0"},
+				},
+			},
+			want: &DiffReport{
+				Added:      []Component{{Path: "github.com/foo/d", Version: "v0.2.0"}},
+				Removed:    []Component{{Path: "github.com/foo/c", Version: "v0.1.0"}},
+				Upgraded:   []VersionChange{{Path: "github.com/foo/a", Old: "v1.0.0", New: "v1.1.0"}},
+				Downgraded: []VersionChange{{Path: "github.com/foo/b", Old: "v1.2.0", New: "v1.1.0"}},
+				LicenseChanged: []VersionChange{
+					{Path: "github.com/foo/a", Old: "MIT", New: "Apache-2.0"},
+				},
+				Platforms: []PlatformDiff{
+					{Platform: "darwin_arm64", Status: "added", Added: []string{"github.com/foo/a"}},
+					{Platform: "linux_amd64", Added: []string{"github.com/foo/d"}, Removed: []string{"github.com/foo/c"}},
+					{Platform: "windows_386", Status: "removed", Removed: []string{"github.com/foo/a"}},
+				},
+			},
+		}, {
+			name: "no changes",
+			old:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123456789abcdef"}}},
+			new:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123456789abcdef"}}},
+			want: &DiffReport{
+				Added:          []Component{},
+				Removed:        []Component{},
+				Upgraded:       []VersionChange{},
+				Downgraded:     []VersionChange{},
+				LicenseChanged: []VersionChange{},
+				Platforms:      []PlatformDiff{},
+			},
+		}, {
+			name: "license text",
+			old:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123456789abcdef"}}},
+			new:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "fedcba9876543210"}}},
+			want: &DiffReport{
+				Added:          []Component{},
+				Removed:        []Component{},
+				Upgraded:       []VersionChange{},
+				Downgraded:     []VersionChange{},
+				LicenseChanged: []VersionChange{{Path: "github.com/foo/a", Old: "sha256:0123456789abcdef", New: "sha256:fedcba9876543210"}},
+				Platforms:      []PlatformDiff{},
+			},
+		}, {
+			name: "short license hash",
+			old:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123"}}},
+			new:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "fedc"}}},
+			want: &DiffReport{
+				Added:          []Component{},
+				Removed:        []Component{},
+				Upgraded:       []VersionChange{},
+				Downgraded:     []VersionChange{},
+				LicenseChanged: []VersionChange{{Path: "github.com/foo/a", Old: "sha256:0123", New: "sha256:fedc"}},
+				Platforms:      []PlatformDiff{},
+			},
+		}, {
+			name: "full and short license hash",
+			old:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123456789abcdef"}}},
+			new:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123456789ab"}}},
+			want: &DiffReport{
+				Added:          []Component{},
+				Removed:        []Component{},
+				Upgraded:       []VersionChange{},
+				Downgraded:     []VersionChange{},
+				LicenseChanged: []VersionChange{},
+				Platforms:      []PlatformDiff{},
+			},
+		}, {
+			name: "same prefix license hash",
+			old:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123456789ab0000"}}},
+			new:  Snapshot{"": {{Path: "github.com/foo/a", LicenseHash: "0123456789ab1111"}}},
+			want: &DiffReport{
+				Added:          []Component{},
+				Removed:        []Component{},
+				Upgraded:       []VersionChange{},
+				Downgraded:     []VersionChange{},
+				LicenseChanged: []VersionChange{{Path: "github.com/foo/a", Old: "sha256:0123456789ab0000", New: "sha256:0123456789ab1111"}},
+				Platforms:      []PlatformDiff{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Diff(tt.old, tt.new), "Diff()")
+		})
+	}
+}
+
+func TestDiffReport_WriteMarkdown(t *testing.T) {
+	r := &DiffReport{
+		Added:    []Component{{Path: "github.com/foo/d", Version: "v0.2.0", License: "MIT"}},
+		Upgraded: []VersionChange{{Path: "github.com/foo/a", Old: "v1.0.0", New: "v1.1.0"}},
+		Platforms: []PlatformDiff{
+			{Platform: "linux_amd64", Added: []string{"github.com/foo/d", "github.com/foo/e"}},
+		},
+	}
+	b := &strings.Builder{}
+	err := r.WriteMarkdown(b)
+	assert.Nil(t, err, "WriteMarkdown()")
+	assert.Equal(t, "### Added\n\n"+
+		"- `github.com/foo/d` v0.2.0 (MIT)\n\n"+
+		"### Upgraded\n\n"+
+		"- `github.com/foo/a` v1.0.0 -> v1.1.0\n\n"+
+		"### Platforms\n\n"+
+		"| Platform | Status | Added | Removed |\n|---|---|---|---|\n"+
+		"| linux_amd64 |  | github.com/foo/d<br>github.com/foo/e |  |\n\n", b.String(), "WriteMarkdown()")
+
+	b.Reset()
+	err = (&DiffReport{}).WriteMarkdown(b)
+	assert.Nil(t, err, "WriteMarkdown()")
+	assert.Equal(t, "No changes in third-party components.\n", b.String(), "WriteMarkdown()")
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	workDir := filepath.Join(cwd, "testdata", "work_diff")
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	writeCredits := func(name string, licenses ...[]string) {
+		b := &strings.Builder{}
+		for _, l := range licenses {
+			writeLicense(b, l[0], "https://"+l[0], l[1])
+		}
+		err := ioutil.WriteFile(filepath.Join(workDir, name), []byte(b.String()), 0644)
+		assert.Nil(t, err, "check")
+	}
+	writeCredits("CREDITS_linux_amd64",
+		[]string{"github.com/foo/a", "SPDX-License-Identifier: MIT"},
+		[]string{"github.com/foo/b", "license text"},
+	)
+	writeCredits("CREDITS_windows_386", []string{"github.com/foo/a", "SPDX-License-Identifier: MIT"})
+	err = ioutil.WriteFile(filepath.Join(workDir, ".CREDITS_linux_386_123.tmp"), []byte("tmp"), 0644)
+	assert.Nil(t, err, "check")
+	// 他の output のファイルは読まない.
+	err = ioutil.WriteFile(filepath.Join(workDir, "CREDITS_linux_amd64.json"), []byte(`{"modules": [], "credits": []}`), 0644)
+	assert.Nil(t, err, "check")
+
+	got, err := LoadSnapshot(workDir, "CREDITS")
+	assert.Nil(t, err, "LoadSnapshot()")
+	assert.Equal(t, []string{"linux_amd64", "windows_386"}, got.platforms(), "LoadSnapshot()")
+	if assert.Len(t, got["linux_amd64"], 2, "LoadSnapshot()") {
+		assert.Equal(t, "MIT", got["linux_amd64"][0].License, "LoadSnapshot()")
+		assert.Equal(t, "", got["linux_amd64"][1].License, "LoadSnapshot()")
+		assert.Len(t, got["linux_amd64"][1].LicenseHash, 64, "LoadSnapshot()")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(workDir, "new.json"), []byte(`[
+  {"path": "github.com/foo/a", "version": "v1.0.0", "license": "MIT"},
+  {"name": "github.com/foo/b", "url": "https://github.com/foo/b", "content": "SPDX-License-Identifier: BSD-3-Clause"}
+]`), 0644)
+	assert.Nil(t, err, "check")
+	got, err = LoadSnapshot(filepath.Join(workDir, "new.json"), "CREDITS")
+	assert.Nil(t, err, "LoadSnapshot()")
+	if assert.Len(t, got[""], 2, "LoadSnapshot()") {
+		assert.Equal(t, Component{Path: "github.com/foo/a", Version: "v1.0.0", License: "MIT"}, got[""][0], "LoadSnapshot()")
+		assert.Equal(t, "BSD-3-Clause", got[""][1].License, "LoadSnapshot()")
+	}
+
+	got, err = LoadSnapshot(workDir, "CREDITS.json")
+	assert.Nil(t, err, "LoadSnapshot()")
+	assert.Equal(t, Snapshot{"linux_amd64": {}}, got, "LoadSnapshot()")
+
+	_, err = LoadSnapshot(filepath.Join(workDir, "foo"), "CREDITS")
+	assert.ErrorIs(t, err, os.ErrNotExist, "LoadSnapshot()")
+}
+
+func TestLoadSnapshotFS(t *testing.T) {
+	outDir := filepath.Join(string(filepath.Separator), "out")
+	m := NewMemFS()
+	err := m.MkdirAll(outDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	b := &strings.Builder{}
+	writeLicense(b, "github.com/foo/a", "https://github.com/foo/a", "SPDX-License-Identifier: MIT")
+	for _, n := range []string{"CREDITS", "CREDITS_linux_amd64"} {
+		err = m.WriteFile(filepath.Join(outDir, n), []byte(b.String()), 0644)
+		assert.Nil(t, err, "check")
+	}
+
+	got, err := LoadSnapshotFS(m, outDir, "CREDITS")
+	assert.Nil(t, err, "LoadSnapshotFS()")
+	assert.Equal(t, Snapshot{
+		"":            {{Path: "github.com/foo/a", License: "MIT", LicenseHash: got[""][0].LicenseHash}},
+		"linux_amd64": {{Path: "github.com/foo/a", License: "MIT", LicenseHash: got[""][0].LicenseHash}},
+	}, got, "LoadSnapshotFS()")
+
+	// OS のファイルは読まない.
+	_, err = LoadSnapshotFS(m, filepath.Join(outDir, "foo"), "CREDITS")
+	assert.ErrorIs(t, err, fs.ErrNotExist, "LoadSnapshotFS()")
+}
+
+func TestParseComponents(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []Component
+		wantErr bool
+	}{
+		{
+			name: "RenderJSON",
+			in: `{
+  "modules": [{"path": "github.com/foo/a", "version": "v1.0.0"}, {"path": "github.com/foo/b", "version": "v0.1.0"}],
+  "credits": [
+    {"name": "Go", "url": "https://golang.org", "content": "SPDX-License-Identifier: BSD-3-Clause"},
+    {"name": "github.com/foo/a", "url": "https://github.com/foo/a", "content": "SPDX-License-Identifier: MIT"}
+  ]
+}`,
+			want: []Component{
+				{Path: "github.com/foo/a", Version: "v1.0.0", License: "MIT", LicenseHash: componentOf("", "SPDX-License-Identifier: MIT").LicenseHash},
+				{Path: "github.com/foo/b", Version: "v0.1.0"},
+				{Path: "Go", License: "BSD-3-Clause", LicenseHash: componentOf("", "SPDX-License-Identifier: BSD-3-Clause").LicenseHash},
+			},
+		}, {
+			name: "empty",
+			in:   "\n",
+			want: []Component{},
+		}, {
+			name:    "unknown object",
+			in:      `{"foo": 1}`,
+			wantErr: true,
+		}, {
+			name:    "unknown text",
+			in:      "foo\nbar\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseComponents([]byte(tt.in))
+			if tt.wantErr {
+				assert.NotNil(t, err, "ParseComponents()")
+				return
+			}
+			assert.Nil(t, err, "ParseComponents()")
+			assert.Equal(t, tt.want, got, "ParseComponents()")
+		})
+	}
+}
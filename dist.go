@@ -5,11 +5,17 @@ package ac
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Dist provide functions to write some CREDITS files for each binary files
@@ -20,20 +26,40 @@ type Dist interface {
 	Run() error
 }
 
+// Planner is implemented by Dist that reports what Run does.
+// The Dist built by DistBuilder implements it.
+type Planner interface {
+	// Plan returns what Run does without running any generator or writing files.
+	Plan() (Plan, error)
+}
+
 // DistBuilder builds Dist.
 type DistBuilder interface {
 	WorkDir(string) DistBuilder
 	DistDir(string) DistBuilder
+	Image(string) DistBuilder
 	OutDir(string) DistBuilder
 	BaseName(string) DistBuilder
 	ReplaceOs([][]string) DistBuilder
 	ReplaceArch([][]string) DistBuilder
 	Uniq(bool) DistBuilder
+	Dedup(bool) DistBuilder
+	ContinueOnError(bool) DistBuilder
+	Archive(ArchiveConfig) DistBuilder
+	Checksums(bool) DistBuilder
+	AppendChecksums(string) DistBuilder
+	Attestations(bool) DistBuilder
+	Hash(Hasher) DistBuilder
+	Normalize(Normalizer) DistBuilder
+	FS(WritableFS) DistBuilder
+	Runner(Runner) DistBuilder
 
 	OutputBuilder(OutputBuilder) DistBuilder
+	Outputs([]DistOutput) DistBuilder
 
 	OutStream(io.Writer) DistBuilder
 	ErrStream(io.Writer) DistBuilder
+	OnEvent(func(Event)) DistBuilder
 
 	Branch() DistBuilder
 	Build() Dist
@@ -42,16 +68,30 @@ type DistBuilder interface {
 type baseDistBuilder struct {
 	workDir     string
 	distDir     string
+	image       string
 	outDir      string
 	baseName    string
 	replaceOs   [][]string
 	replaceArch [][]string
 	uniq        bool
+	dedup       bool
+
+	continueOnError bool
+	archiveConfig   ArchiveConfig
+	checksums       bool
+	appendChecksums string
+	attestations    bool
+	hasher          Hasher
+	normalizer      Normalizer
+	fs              WritableFS
+	runner          Runner
 
 	outputBuilder OutputBuilder
+	outputs       []DistOutput
 
 	outStream io.Writer
 	errStream io.Writer
+	onEvent   func(Event)
 }
 
 func (b *baseDistBuilder) WorkDir(workDir string) DistBuilder {
@@ -66,12 +106,23 @@ func (b *baseDistBuilder) DistDir(distDir string) DistBuilder {
 	return bb
 }
 
+// Image sets the path of the image tarball(`docker save` or OCI layout).
+// The Go executables in the image are also the targets of Dist.
+// DistDir is not scanned if it is empty.
+func (b *baseDistBuilder) Image(image string) DistBuilder {
+	bb := b.branch()
+	b.image = image
+	return bb
+}
+
 func (b *baseDistBuilder) OutDir(outDir string) DistBuilder {
 	bb := b.branch()
 	b.outDir = outDir
 	return bb
 }
 
+// BaseName sets the base name of the output files(CREDITS by default).
+// It is ignored if Outputs is set(BaseName of the first output is used).
 func (b *baseDistBuilder) BaseName(baseName string) DistBuilder {
 	bb := b.branch()
 	b.baseName = baseName
@@ -90,18 +141,112 @@ func (b *baseDistBuilder) ReplaceArch(replaceArch [][]string) DistBuilder {
 	return bb
 }
 
+// Uniq merges the files of all platforms into BaseName if they have the same content.
+// It is ignored if Outputs is set(Uniq of each output is used).
 func (b *baseDistBuilder) Uniq(uniq bool) DistBuilder {
 	bb := b.branch()
 	b.uniq = uniq
 	return bb
 }
 
+// Dedup runs the generator once per distinct module set instead of once per platform,
+// and fans the result out to the file of each platform.
+// The generator should write the same content for the same module set.
+func (b *baseDistBuilder) Dedup(dedup bool) DistBuilder {
+	bb := b.branch()
+	b.dedup = dedup
+	return bb
+}
+
+// ContinueOnError processes every platform, writes the files of the succeeded platforms and
+// returns the errors of the failed platforms as RunError.
+func (b *baseDistBuilder) ContinueOnError(continueOnError bool) DistBuilder {
+	bb := b.branch()
+	b.continueOnError = continueOnError
+	return bb
+}
+
+func (b *baseDistBuilder) Archive(archiveConfig ArchiveConfig) DistBuilder {
+	bb := b.branch()
+	b.archiveConfig = archiveConfig
+	return bb
+}
+
+// Checksums enables the checksum manifest(<BaseName>.sha256 in OutDir) that lists
+// the files written by Dist in the format of sha256sum.
+func (b *baseDistBuilder) Checksums(checksums bool) DistBuilder {
+	bb := b.branch()
+	b.checksums = checksums
+	return bb
+}
+
+// AppendChecksums sets the existing checksums file(ie. checksums.txt of goreleaser).
+// The lines of the files written by Dist are added(or replaced) to it instead of <BaseName>.sha256.
+func (b *baseDistBuilder) AppendChecksums(appendChecksums string) DistBuilder {
+	bb := b.branch()
+	b.appendChecksums = appendChecksums
+	return bb
+}
+
+// Attestations enables the unsigned in-toto Statement of each binary(ie. CREDITS.linux_amd64.intoto.json)
+// that links the binary to its CREDITS files(see Statement).
+func (b *baseDistBuilder) Attestations(attestations bool) DistBuilder {
+	bb := b.branch()
+	b.attestations = attestations
+	return bb
+}
+
+// Hash sets the hash algorithm that is used to uniq the outputs(SHA256 by default).
+// It is also set to OutputBuilder.
+func (b *baseDistBuilder) Hash(hasher Hasher) DistBuilder {
+	bb := b.branch()
+	b.hasher = hasher
+	return bb
+}
+
+// Normalize sets Normalizer that is applied to the outputs before they are compared by uniq.
+// The written files, the hash of Flush and Output.Digest are not normalized.
+func (b *baseDistBuilder) Normalize(normalizer Normalizer) DistBuilder {
+	bb := b.branch()
+	b.normalizer = normalizer
+	return bb
+}
+
+// FS sets the file system that DistDir is read from and the outputs are written into(OSFS by default).
+// It is also set to OutputBuilder.
+// The archives(DistDir, Archive) and the image are read and written by the file system of the OS,
+// Run returns ErrUnsupportedFS if the file system other than OSFS is used with them.
+func (b *baseDistBuilder) FS(fsys WritableFS) DistBuilder {
+	bb := b.branch()
+	b.fs = fsys
+	return bb
+}
+
+// Runner sets Runner that runs the commands of OutputBuilder(see OutputBuilder.Runner).
+func (b *baseDistBuilder) Runner(runner Runner) DistBuilder {
+	bb := b.branch()
+	b.runner = runner
+	return bb
+}
+
 func (b *baseDistBuilder) OutputBuilder(outputBuilder OutputBuilder) DistBuilder {
 	bb := b.branch()
 	b.outputBuilder = outputBuilder.Branch()
 	return bb
 }
 
+// Outputs sets the outputs that are written from the shared result of each platform.
+// The modules are resolved and the generator runs once, and then each output renders its file.
+// The first output is the primary output, its BaseName is used as BaseName(Plan, archives and the sidecar file).
+// BaseName and Uniq of DistBuilder are ignored if it is set.
+// If it is not set, the single output(BaseName, Uniq, RenderText) is used, and the platform is appended to
+// BaseName(ie. CREDITS_linux_amd64) instead of being inserted before the extension.
+func (b *baseDistBuilder) Outputs(outputs []DistOutput) DistBuilder {
+	bb := b.branch()
+	b.outputs = outputs
+	return bb
+}
+
 func (b *baseDistBuilder) OutStream(outStream io.Writer) DistBuilder {
 	bb := b.branch()
 	b.outStream = outStream
@@ -114,6 +259,14 @@ func (b *baseDistBuilder) ErrStream(errStream io.Writer) DistBuilder {
 	return bb
 }
 
+// OnEvent sets the hook that receives the events fired while Dist runs.
+// No events are reported by default(use NewEventLogger to write them into OutStream).
+func (b *baseDistBuilder) OnEvent(onEvent func(Event)) DistBuilder {
+	bb := b.branch()
+	b.onEvent = onEvent
+	return bb
+}
+
 func (b *baseDistBuilder) branch() *baseDistBuilder {
 	return &(*b) // とりあえず
 }
@@ -128,116 +281,493 @@ func (b *baseDistBuilder) Build() Dist {
 
 type outputHash struct {
 	outFileName string
+	tmpFileName string
 	hash        []byte
 }
 
+// DistExclusions is the record of the modules excluded from the CREDITS file of the platform.
+type DistExclusions struct {
+	Platform string      `json:"platform"`
+	Binary   string      `json:"binary"`
+	Modules  []Exclusion `json:"modules"`
+}
+
 type baseDist struct {
 	workDir     string
 	distDir     string
+	image       string
 	outDir      string
 	baseName    string
 	replaceOs   [][]string
 	replaceArch [][]string
 	uniq        bool
+	dedup       bool
+
+	continueOnError bool
+	archiveConfig   ArchiveConfig
+	checksums       bool
+	appendChecksums string
+	attestations    bool
+	hasher          Hasher
+	normalizer      Normalizer
+	fs              WritableFS
+	runner          Runner
 
 	outputBuilder OutputBuilder
+	outputs       []DistOutput
 
 	outStream io.Writer
 	errStream io.Writer
+	onEvent   func(Event)
 
 	builder DistBuilder
 
 	// hash []outputHash
 	hash []*outputHash
+	// sidecar は CREDITS 以外に書き出すファイル.
+	sidecar []*outputHash
+	// archives は Archive で作成(更新)したアーカイブ.
+	archives []*outputHash
+	// archiveSums は Archive で更新した checksums ファイル.
+	archiveSums *outputHash
+	// imageIndex は Image の tarball のインデックス.
+	imageIndex *tarIndex
+
+	excluded []DistExclusions
+}
+
+// tempPattern returns the directory and the pattern of the temporary file of outFileName.
+// It is renamed to the final file name by commit after the whole run succeeds.
+func (d *baseDist) tempPattern(outFileName string) (string, string) {
+	if filepath.Dir(outFileName) == filepath.Clean(d.outDir) {
+		return d.outDir, "." + d.baseName + "_*.tmp"
+	}
+	return filepath.Dir(outFileName), "." + filepath.Base(outFileName) + "_*.tmp"
+}
+
+// distJob is the output of the platform.
+type distJob struct {
+	entry PlanEntry
+	out   *bytes.Buffer
+	o     stagedOutput
+
+	generator string        // 共有された結果を生成した generator.
+	files     []*outputHash // 各 output で書き出された(uniq された場合はマージされた)ファイル.
+}
+
+// input returns the shared result of the job that is passed to the renderers.
+func (j *distJob) input() RenderInput {
+	return RenderInput{
+		Platform: j.entry.Platform(),
+		Binary:   j.entry.Binary,
+		Modules:  j.o.resolved(),
+		Excluded: j.o.Excluded(),
+		Packages: j.o.Packages(),
+		Credits:  j.out.Bytes(),
+	}
+}
+
+// suffixOf returns the suffix of the output file of the entry(ie. linux_amd64).
+func (d *baseDist) suffixOf(e PlanEntry) string {
+	if s, ok := d.outputs[0].fileSuffix(filepath.Base(e.OutFile)); ok {
+		return s
+	}
+	return e.Platform()
 }
 
-func (d *baseDist) output(distName string) error {
-	s := DistSuffix(distName)
-	pOs := ReplaceItem(d.replaceOs, s[0])
-	pArch := ReplaceItem(d.replaceArch, s[1])
+// resolve resolves the modules of the platform.
+func (d *baseDist) resolve(e PlanEntry) (*distJob, error) {
+	if err := d.extract(e); err != nil {
+		return nil, err
+	}
+	j := &distJob{
+		entry: e,
+		out:   &bytes.Buffer{},
+	}
+	o, ok := d.outputBuilder.Binary(e.Binary).OutStream(j.out).Build().(stagedOutput)
+	if ok == false {
+		return nil, newPlatformError(e.Platform(), e.Binary, fmt.Errorf("output can not be staged"))
+	}
+	j.o = o
+	if err := o.resolve(); err != nil {
+		return nil, newPlatformError(e.Platform(), e.Binary, err)
+	}
+	d.fire(Event{Kind: EventModulesResolved, Platform: e.Platform(), Binary: e.Binary, Count: len(o.resolved())})
+	if excluded := o.Excluded(); len(excluded) > 0 {
+		d.excluded = append(d.excluded, DistExclusions{
+			Platform: e.Platform(),
+			Binary:   e.Binary,
+			Modules:  excluded,
+		})
+	}
+	return j, nil
+}
+
+// render runs the generator once for the jobs that have the same module set.
+// The result is shared by the jobs.
+func (d *baseDist) render(jobs []*distJob) error {
+	j := jobs[0]
+	d.fire(Event{Kind: EventGeneratorStarted, Platform: j.entry.Platform(), Binary: j.entry.Binary, Count: len(jobs)})
+	start := time.Now()
+	hash, err := j.o.render()
+	d.fire(Event{Kind: EventGeneratorFinished, Platform: j.entry.Platform(), Binary: j.entry.Binary, Duration: time.Since(start), Hash: hash, Err: err})
+	if err != nil {
+		errs := make([]error, len(jobs))
+		for i, jj := range jobs {
+			errs[i] = newPlatformError(jj.entry.Platform(), jj.entry.Binary, err)
+		}
+		return &RunError{Errs: errs}
+	}
+	for _, jj := range jobs {
+		jj.out = j.out
+		jj.generator = j.o.generator()
+	}
+	return nil
+}
+
+// writeOutput renders the file of each job, and writes them into the temporary files.
+// If merged is true(or the files have the same content and o.Uniq is true), they are merged into o.BaseName.
+func (d *baseDist) writeOutput(o DistOutput, jobs []*distJob, merged bool) error {
+	contents := make([][]byte, len(jobs))
+	for i, j := range jobs {
+		b := &bytes.Buffer{}
+		if err := o.render(b, j.input()); err != nil {
+			return newPlatformError(j.entry.Platform(), j.entry.Binary, stageErr(StageGenerate, wrapf(err, "rendering %s", o.Name)))
+		}
+		contents[i] = b.Bytes()
+	}
+	if o.Uniq && merged == false {
+		merged = true
+		first := normalizedSum(d.hasher, d.normalizer, contents[0])
+		for _, c := range contents[1:] {
+			if bytes.Equal(first, normalizedSum(d.hasher, d.normalizer, c)) == false {
+				merged = false
+				break
+			}
+		}
+		if merged && len(jobs) > 1 {
+			d.fire(Event{Kind: EventMerged, Path: filepath.Join(d.outDir, o.BaseName), Count: len(jobs)})
+		}
+	}
+	if merged {
+		f, err := d.writeTemp(filepath.Join(d.outDir, o.BaseName), contents[0])
+		if err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			j.files = append(j.files, f)
+		}
+		return nil
+	}
+	for i, j := range jobs {
+		f, err := d.writeTemp(filepath.Join(d.outDir, o.fileName(d.suffixOf(j.entry))), contents[i])
+		if err != nil {
+			return err
+		}
+		j.files = append(j.files, f)
+	}
+	return nil
+}
 
-	outFileName := filepath.Join(d.outDir, d.baseName+"_"+pOs+"_"+pArch)
-	out, err := os.Create(outFileName)
+// writeTemp writes b into the temporary file that is renamed to outFileName by commit.
+func (d *baseDist) writeTemp(outFileName string, b []byte) (*outputHash, error) {
+	f, err := d.writeTempFile(outFileName, b)
 	if err != nil {
-		return wrapf(err, "output creating the output file")
+		return nil, wrapf(err, "output")
 	}
-	defer out.Close()
+	d.hash = append(d.hash, f)
+	return f, nil
+}
 
-	o := d.outputBuilder.Binary(filepath.Join(d.distDir, distName)).OutStream(out).Build()
-	hash, err := o.Flush()
-	d.hash = append(d.hash, &outputHash{
+// writeTempFile writes b into the temporary file in the directory of outFileName.
+func (d *baseDist) writeTempFile(outFileName string, b []byte) (*outputHash, error) {
+	hash := sha256.Sum256(b)
+	dir, pattern := d.tempPattern(outFileName)
+	name, err := writeTempFS(d.fs, dir, pattern, b, 0644)
+	if err != nil {
+		if name != "" {
+			d.fs.Remove(name)
+		}
+		return nil, wrapf(err, "writing the output file")
+	}
+	return &outputHash{
 		outFileName: outFileName,
-		hash:        hash,
-	})
-	return err
+		tmpFileName: name,
+		hash:        hash[:],
+	}, nil
+}
+
+// writeExcluded writes the excluded modules into the sidecar file(ie. CREDITS.excluded.json).
+func (d *baseDist) writeExcluded() error {
+	if len(d.excluded) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(struct {
+		Excluded []DistExclusions `json:"excluded"`
+	}{d.excluded}, "", "  ")
+	if err != nil {
+		return wrapf(err, "writeExcluded encoding")
+	}
+	f, err := d.writeTempFile(filepath.Join(d.outDir, d.baseName+".excluded.json"), append(b, '\n'))
+	if err != nil {
+		return wrapf(err, "writeExcluded")
+	}
+	d.sidecar = append(d.sidecar, f)
+	return nil
 }
 
-func (d *baseDist) uniqByHash() (uniqed bool, err error) {
-	l := len(d.hash)
-	t := d.hash[0]
-	p, _ := filepath.Split(t.outFileName)
-	dstFileName := filepath.Join(p, d.baseName)
-	for i := 1; i < l; i++ {
-		if bytes.Equal(t.hash, d.hash[i].hash) == false {
-			return false, nil
+// commit renames the temporary files into place, and removes the stale files
+// that are not produced by this run(ie. CREDITS_* of the removed platform).
+func (d *baseDist) commit() error {
+	files := d.pending()
+	if err := d.rename(files); err != nil {
+		return err
+	}
+	produced := map[string]bool{}
+	for _, f := range files {
+		produced[filepath.Base(f.outFileName)] = true
+	}
+	if d.checksums && d.appendChecksums == "" {
+		// マニフェストはアーカイブの後に書き出す.
+		produced[d.checksumsName()] = true
+	}
+	return d.removeStale(produced)
+}
+
+// rename renames the temporary files into place.
+func (d *baseDist) rename(files []*outputHash) error {
+	for _, f := range files {
+		if err := d.fs.Rename(f.tmpFileName, f.outFileName); err != nil {
+			return wrapf(err, "commit renaming file")
 		}
+		f.tmpFileName = ""
+		d.fire(Event{Kind: EventOutputWritten, Path: f.outFileName, Hash: f.hash})
+	}
+	return nil
+}
+
+// isStaleCandidate reports whether name in OutDir is the file that may be written by Dist.
+// The files of the platforms are limited to the names whose suffix is the platform,
+// so that the other files(ie. CREDITS_notes.md) are not removed.
+func (d *baseDist) isStaleCandidate(name string) bool {
+	switch name {
+	case d.baseName + ".excluded.json", d.checksumsName():
+		return true
+	}
+	if s, ok := d.statementSuffix(name); ok {
+		return isPlatformSuffix(s, d.replaceOs, d.replaceArch)
+	}
+	if n := strings.TrimSuffix(name, ".intoto.json"); n != name {
+		// 以前の名前の statement(ie. CREDITS_linux_amd64.intoto.json).
+		s, ok := d.outputs[0].fileSuffix(n)
+		return ok && isPlatformSuffix(s, d.replaceOs, d.replaceArch)
+	}
+	for _, o := range d.outputs {
+		if name == o.BaseName {
+			return true
+		}
+		if s, ok := o.fileSuffix(name); ok && isPlatformSuffix(s, d.replaceOs, d.replaceArch) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *baseDist) removeStale(produced map[string]bool) error {
+	entries, err := fs.ReadDir(d.fs, d.outDir)
+	if err != nil {
+		return wrapf(err, "removeStale")
 	}
-	for i := 1; i < l; i++ {
-		if err := os.Remove(d.hash[i].outFileName); err != nil {
-			return false, wrapf(err, "uniqByHash removeing files")
+	for _, e := range entries {
+		if produced[e.Name()] || d.isStaleCandidate(e.Name()) == false {
+			continue
+		}
+		m := filepath.Join(d.outDir, e.Name())
+		if s, err := fs.Stat(d.fs, m); err != nil || s.Mode().IsRegular() == false {
+			continue
+		}
+		if err := d.fs.Remove(m); err != nil {
+			return wrapf(err, "removeStale")
 		}
 	}
-	if err := os.Rename(t.outFileName, dstFileName); err != nil {
-		return false, wrapf(err, "uniqByHash renaming file")
+	return nil
+}
+
+// pending returns the files those are written into the temporary files.
+func (d *baseDist) pending() []*outputHash {
+	files := append(append(append([]*outputHash{}, d.hash...), d.sidecar...), d.archives...)
+	if d.archiveSums != nil {
+		files = append(files, d.archiveSums)
+	}
+	return files
+}
+
+// discard removes the temporary files those are not committed.
+func (d *baseDist) discard() {
+	for _, f := range d.pending() {
+		if f.tmpFileName != "" {
+			d.fs.Remove(f.tmpFileName)
+		}
 	}
-	return true, nil
 }
 
 func (d *baseDist) Run() error {
-	dirs, err := ioutil.ReadDir(d.distDir)
+	if isOSFS(d.fs) == false && (d.archiveConfig.Format != "" || d.image != "") {
+		return wrapf(ErrUnsupportedFS, "Dist.Run")
+	}
+	plan, err := d.plan()
 	if err != nil {
 		return wrapf(err, "Dist.Run")
 	}
-	for _, p := range dirs {
-		if p.IsDir() {
-			// go.sum を上書きしているので、並列で動かさないように注意.
-			if err := d.output(p.Name()); err != nil {
+	d.hash = []*outputHash{}
+	d.sidecar = nil
+	d.archives = nil
+	d.archiveSums = nil
+	d.excluded = nil
+	defer d.discard()
+
+	// 先に各プラットフォームのモジュールを解決し、同じモジュールの組み合わせごとにまとめる.
+	errs := []error{}
+	keys := []string{}
+	groups := map[string][]*distJob{}
+	for _, e := range plan {
+		d.fire(Event{Kind: EventPlatformDiscovered, Platform: e.Platform(), Binary: e.Binary})
+	}
+	for i, e := range plan {
+		j, err := d.resolve(e)
+		if err != nil {
+			if d.continueOnError == false {
 				return wrapf(err, "Dist.Run")
 			}
+			d.fire(Event{Kind: EventSkipped, Platform: e.Platform(), Binary: e.Binary, Err: err})
+			errs = append(errs, err)
+			continue
+		}
+		k := strconv.Itoa(i)
+		if d.dedup {
+			k = j.o.moduleSetKey()
 		}
+		if _, ok := groups[k]; ok == false {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], j)
 	}
-	if len(d.hash) == 0 {
-		return wrapf(fmt.Errorf(" No %s file(s) has been created", d.baseName), "Dist.Run")
+
+	// 全てのモジュールが同じであれば generator を実行する前に uniq できる(Dedup の場合のみ).
+	// (Render が指定された output はプラットフォームに依存する場合があるので書き出す時に比較する)
+	merged := len(keys) == 1 && len(errs) == 0
+	if merged {
+		if n := len(groups[keys[0]]); n > 1 {
+			for _, o := range d.outputs {
+				if o.Uniq && o.Render == nil {
+					d.fire(Event{Kind: EventMerged, Path: filepath.Join(d.outDir, o.BaseName), Count: n})
+				}
+			}
+		}
 	}
-	if d.uniq {
-		_, err := d.uniqByHash()
-		if err != nil {
+	jobs := []*distJob{}
+	for _, k := range keys {
+		// go.sum を上書きしているので、並列で動かさないように注意.
+		if err := d.render(groups[k]); err != nil {
+			var runErr *RunError
+			if errors.As(err, &runErr) == false {
+				return wrapf(err, "Dist.Run")
+			}
+			if d.continueOnError == false {
+				return wrapf(runErr.Errs[0], "Dist.Run")
+			}
+			for _, j := range groups[k] {
+				d.fire(Event{Kind: EventSkipped, Platform: j.entry.Platform(), Binary: j.entry.Binary, Err: err})
+			}
+			errs = append(errs, runErr.Errs...)
+			continue
+		}
+		jobs = append(jobs, groups[k]...)
+	}
+	if len(jobs) == 0 {
+		if len(errs) > 0 {
+			return &RunError{Errs: errs}
+		}
+		return fmt.Errorf("Dist.Run %s: %w", d.baseName, ErrNoOutputs)
+	}
+	for _, o := range d.outputs {
+		if err := d.writeOutput(o, jobs, merged && o.Uniq && o.Render == nil); err != nil {
 			return wrapf(err, "Dist.Run")
 		}
 	}
+	if err := d.writeExcluded(); err != nil {
+		return wrapf(err, "Dist.Run")
+	}
+	if err := d.writeStatements(jobs); err != nil {
+		return wrapf(err, "Dist.Run")
+	}
+	// アーカイブも一時ファイルに書き出し、CREDITS ファイルと一緒に commit する.
+	if err := d.archive(jobs); err != nil {
+		return wrapf(err, "Dist.Run")
+	}
+	if err := d.commit(); err != nil {
+		return wrapf(err, "Dist.Run")
+	}
+	if err := d.writeChecksumManifest(); err != nil {
+		return wrapf(err, "Dist.Run")
+	}
+	if len(errs) > 0 {
+		// 成功したプラットフォームは書き出し、失敗したものをまとめて返す.
+		return &RunError{Errs: errs}
+	}
 	return nil
 }
 
 func newBaseDist(b *baseDistBuilder) *baseDist {
-	return &baseDist{
+	d := &baseDist{
 		workDir:     b.workDir,
 		distDir:     b.distDir,
+		image:       b.image,
 		outDir:      b.outDir,
 		baseName:    b.baseName,
 		replaceOs:   b.replaceOs,
 		replaceArch: b.replaceArch,
 		uniq:        b.uniq,
+		dedup:       b.dedup,
+
+		continueOnError: b.continueOnError,
+		archiveConfig:   b.archiveConfig,
+		checksums:       b.checksums,
+		appendChecksums: b.appendChecksums,
+		attestations:    b.attestations,
+		hasher:          b.hasher,
+		normalizer:      b.normalizer,
+		fs:              b.fs,
+		runner:          b.runner,
 
 		outputBuilder: b.outputBuilder.Branch().
 			WorkDir(b.workDir),
+		outputs: b.outputs,
 
 		outStream: b.outStream,
 		errStream: b.errStream,
+		onEvent:   b.onEvent,
 
 		builder: b.branch(),
 
 		hash: []*outputHash{},
 	}
+	if b.hasher.New != nil {
+		d.outputBuilder = d.outputBuilder.Hash(b.hasher)
+	}
+	if b.runner != nil {
+		d.outputBuilder = d.outputBuilder.Runner(b.runner)
+	}
+	if b.fs != nil {
+		d.outputBuilder = d.outputBuilder.FS(b.fs)
+	} else {
+		d.fs = OSFS()
+	}
+	if len(d.outputs) == 0 {
+		d.outputs = []DistOutput{{Name: "credits", BaseName: d.baseName, Uniq: d.uniq, legacy: true}}
+	}
+	d.baseName = d.outputs[0].BaseName
+	return d
 }
 
 // NewDistBuilder returns the instance of DistBuilder.
@@ -4,11 +4,14 @@
 package ac
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -83,6 +86,24 @@ func Test_baseDist_Run_With_Func(t *testing.T) {
 				return err
 			},
 			wantFiles: []string{"CREDITS_Linux_i386", "CREDITS_Linux_amd64", "CREDITS_Linux_amd64_v1"},
+		}, {
+			name: "dedup",
+			builder: NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				Dedup(true).
+				WorkDir(workDir),
+			fakeRunFunc: func(argv []string, outStream, errStream io.Writer) error {
+				// different output, but the generator runs once for the same modules.
+				f, err := os.Open("/dev/random")
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = io.CopyN(outStream, f, 2048*5)
+				return err
+			},
+			wantFiles: []string{"CREDITS"},
 		},
 	}
 	for _, tt := range tests {
@@ -155,6 +176,18 @@ echo test
 dd if=/dev/random count=5 status=none
 `,
 			wantNumFOutput: 3,
+		}, {
+			name: "dedup",
+			builder: NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				Dedup(true).
+				WorkDir(workDir),
+			fakeScript: `#!/bin/sh
+# different output, but the generator runs once for the same modules.
+dd if=/dev/random count=5 status=none
+`,
+			wantNumFOutput: 1,
 		},
 	}
 	for _, tt := range tests {
@@ -201,3 +234,443 @@ dd if=/dev/random count=5 status=none
 		})
 	}
 }
+
+func Test_baseDist_Run_ContinueOnError(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "work_distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+	tests := []struct {
+		name            string
+		continueOnError bool
+		failGenerator   bool
+		wantPlatforms   []string
+		wantStage       Stage
+		wantFiles       []string
+	}{
+		{
+			name:            "stop",
+			continueOnError: false,
+			wantPlatforms:   []string{"linux_arm64"},
+			wantStage:       StageModules,
+		}, {
+			name:            "continue",
+			continueOnError: true,
+			wantPlatforms:   []string{"linux_arm64", "windows_386"},
+			wantStage:       StageModules,
+			wantFiles:       []string{"CREDITS"}, // uniq された成功分.
+		}, {
+			name:            "generator",
+			continueOnError: true,
+			failGenerator:   true,
+			wantPlatforms:   []string{"linux_arm64", "windows_386", "linux_386", "linux_amd64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			err = ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(outDir)
+
+			// linux_arm64, windows_386 は Go のバイナリを含まない.
+			err = ResetDir(distDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(distDir)
+			for _, p := range []string{"linux_386", "linux_amd64"} {
+				b, err := ioutil.ReadFile(filepath.Join(testDir, "distDir", p, "my_cmd"))
+				assert.Nil(t, err, "check")
+				err = os.Mkdir(filepath.Join(distDir, p), os.ModePerm)
+				assert.Nil(t, err, "check")
+				err = ioutil.WriteFile(filepath.Join(distDir, p, "my_cmd"), b, 0755)
+				assert.Nil(t, err, "check")
+			}
+			for _, p := range []string{"linux_arm64", "windows_386"} {
+				err = os.Mkdir(filepath.Join(distDir, p), os.ModePerm)
+				assert.Nil(t, err, "check")
+			}
+
+			d := NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				WorkDir(workDir).
+				ContinueOnError(tt.continueOnError).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							if tt.failGenerator {
+								return fmt.Errorf("fake error")
+							}
+							_, err := io.Copy(outStream, strings.NewReader("test"))
+							return err
+						}),
+				).
+				Build()
+			err = d.Run()
+			assert.NotNil(t, err, "baseDist.Run()")
+
+			var runErr *RunError
+			assert.Equal(t, tt.continueOnError, errors.As(err, &runErr), "RunError")
+			errs := []error{err}
+			if runErr != nil {
+				errs = runErr.Unwrap()
+			}
+			gotPlatforms := []string{}
+			for _, e := range errs {
+				var p *PlatformError
+				assert.True(t, errors.As(e, &p), "PlatformError")
+				gotPlatforms = append(gotPlatforms, p.Platform)
+				if p.Binary == filepath.Join(distDir, p.Platform) {
+					assert.Equal(t, StageModules, p.Stage, "stage")
+					assert.ErrorIs(t, p, ErrNoModules, "error")
+				} else {
+					assert.Equal(t, StageGenerate, p.Stage, "stage")
+					assert.Equal(t, filepath.Join(distDir, p.Platform, "my_cmd"), p.Binary, "binary")
+				}
+			}
+			assert.ElementsMatch(t, tt.wantPlatforms, gotPlatforms, "platforms")
+
+			files, err := ioutil.ReadDir(outDir)
+			assert.Nil(t, err, "check")
+			gotFiles := []string{}
+			for _, f := range files {
+				gotFiles = append(gotFiles, f.Name())
+			}
+			assert.ElementsMatch(t, tt.wantFiles, gotFiles, "written files")
+		})
+	}
+}
+
+func Test_baseDist_Run_Dedup(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+	tests := []struct {
+		name      string
+		uniq      bool
+		wantFiles []string
+	}{
+		{
+			name:      "uniq",
+			uniq:      true,
+			wantFiles: []string{"CREDITS"},
+		}, {
+			name:      "fan out",
+			uniq:      false,
+			wantFiles: []string{"CREDITS_linux_386", "CREDITS_linux_amd64", "CREDITS_linux_amd64_v1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			err = ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(outDir)
+
+			cnt := 0
+			err = NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				WorkDir(workDir).
+				Uniq(tt.uniq).
+				Dedup(true).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							cnt++
+							_, err := fmt.Fprintf(outStream, "test %d", cnt)
+							return err
+						}),
+				).
+				Build().
+				Run()
+			assert.Nil(t, err, "baseDist.Run()")
+			assert.Equal(t, 1, cnt, "generator runs once")
+
+			files, err := ioutil.ReadDir(outDir)
+			assert.Nil(t, err, "check")
+			gotFileNames := make([]string, len(files))
+			for i, f := range files {
+				gotFileNames[i] = f.Name()
+				b, err := ioutil.ReadFile(filepath.Join(outDir, f.Name()))
+				assert.Nil(t, err, "check")
+				assert.Equal(t, "test 1", string(b), "content")
+			}
+			assert.ElementsMatch(t, tt.wantFiles, gotFileNames, "files")
+		})
+	}
+}
+
+func Test_baseDist_Run_Atomic(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+	prevFiles := map[string]string{
+		"CREDITS_linux_386":             "prev",
+		"CREDITS_darwin_amd64":          "prev",
+		"CREDITS_my_cmd_linux_arm_7":    "prev",
+		"CREDITS_notes.md":              "notes",
+		"CREDITS_linux_amd64_notes":     "notes",
+		"CREDITS_linux_386.intoto.json": "prev",
+		"CREDITS.linux_386.intoto.json": "prev",
+		"other.txt":                     "other",
+	}
+	tests := []struct {
+		name      string
+		fail      bool
+		wantFiles map[string]string
+		wantErr   bool
+	}{
+		{
+			name:      "keep previous set",
+			fail:      true,
+			wantFiles: prevFiles,
+			wantErr:   true,
+		}, {
+			name: "new set",
+			wantFiles: map[string]string{
+				"CREDITS":                   "test 1",
+				"CREDITS_notes.md":          "notes",
+				"CREDITS_linux_amd64_notes": "notes",
+				"other.txt":                 "other",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			err = ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(outDir)
+			for n, c := range prevFiles {
+				err := ioutil.WriteFile(filepath.Join(outDir, n), []byte(c), 0644)
+				assert.Nil(t, err, "check")
+			}
+
+			cnt := 0
+			err = NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				WorkDir(workDir).
+				Dedup(true).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							cnt++
+							if _, err := fmt.Fprintf(outStream, "test %d", cnt); err != nil {
+								return err
+							}
+							if tt.fail {
+								return fmt.Errorf("fake error")
+							}
+							return nil
+						}),
+				).
+				Build().
+				Run()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("baseDist.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			files, err := ioutil.ReadDir(outDir)
+			assert.Nil(t, err, "check")
+			gotFiles := map[string]string{}
+			for _, f := range files {
+				b, err := ioutil.ReadFile(filepath.Join(outDir, f.Name()))
+				assert.Nil(t, err, "check")
+				gotFiles[f.Name()] = string(b)
+			}
+			assert.Equal(t, tt.wantFiles, gotFiles, "files")
+		})
+	}
+}
+
+func Test_baseDist_Run_Outputs(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	err = ResetDir(outDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(outDir)
+	// 前回の実行で書き出された(今回は書き出されない)ファイル.
+	err = ioutil.WriteFile(filepath.Join(outDir, "CREDITS_windows_386.json"), []byte("stale"), 0644)
+	assert.Nil(t, err, "check")
+
+	cnt := 0
+	d := NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		Dedup(true).
+		Outputs([]DistOutput{
+			{Name: "credits", BaseName: "CREDITS", Uniq: true},
+			{Name: "json", BaseName: "CREDITS.json", Uniq: false, Render: RenderJSON},
+			{Name: "platform", BaseName: "PLATFORM", Uniq: true, Render: func(w io.Writer, in RenderInput) error {
+				_, err := fmt.Fprintf(w, "%s %d", in.Platform, len(in.Modules))
+				return err
+			}},
+		}).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					cnt++
+					_, err := fmt.Fprintf(outStream, "test %d", cnt)
+					return err
+				}),
+		).
+		Build()
+	err = d.Run()
+	assert.Nil(t, err, "baseDist.Run()")
+	assert.Equal(t, 1, cnt, "generator runs once")
+
+	files, err := ioutil.ReadDir(outDir)
+	assert.Nil(t, err, "check")
+	gotFileNames := make([]string, len(files))
+	for i, f := range files {
+		gotFileNames[i] = f.Name()
+	}
+	assert.ElementsMatch(t, []string{
+		"CREDITS",
+		"CREDITS_linux_386.json", "CREDITS_linux_amd64.json", "CREDITS_linux_amd64_v1.json",
+		"PLATFORM_linux_386", "PLATFORM_linux_amd64", "PLATFORM_linux_amd64_v1",
+	}, gotFileNames, "files")
+
+	b, err := ioutil.ReadFile(filepath.Join(outDir, "PLATFORM_linux_amd64_v1"))
+	assert.Nil(t, err, "check")
+	assert.Equal(t, "linux_amd64_v1 1", string(b), "content")
+	b, err = ioutil.ReadFile(filepath.Join(outDir, "CREDITS_linux_386.json"))
+	assert.Nil(t, err, "check")
+	assert.JSONEq(t, `{
+  "modules": [{"path": "gopkg.in/yaml.v2", "version": "v2.2.2"}],
+  "credits": [{"name": "test 1", "url": "", "content": ""}]
+}`, string(b), "content")
+
+	p, err := d.(Planner).Plan()
+	assert.Nil(t, err, "baseDist.Plan()")
+	assert.Equal(t, filepath.Join(outDir, "CREDITS_linux_386"), p[0].OutFile, "primary output")
+}
+
+func Test_baseDist_Run_Normalize(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		want       []string
+	}{
+		{
+			name: "not normalized",
+			want: []string{"CREDITS_linux_386", "CREDITS_linux_amd64", "CREDITS_linux_amd64_v1"},
+		}, {
+			name:       "normalized",
+			normalizer: DropLines(regexp.MustCompile(`^# linux_`)),
+			want:       []string{"CREDITS"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err = ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(outDir)
+
+			err := NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				WorkDir(workDir).
+				Normalize(tt.normalizer).
+				Outputs([]DistOutput{
+					{Name: "credits", BaseName: "CREDITS", Uniq: true, Render: func(w io.Writer, in RenderInput) error {
+						// 生成日時等のプラットフォームに依存する行.
+						if _, err := fmt.Fprintf(w, "# %s\n", in.Platform); err != nil {
+							return err
+						}
+						return RenderText(w, in)
+					}},
+				}).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							_, err := io.Copy(outStream, strings.NewReader("test"))
+							return err
+						}),
+				).
+				Build().
+				Run()
+			assert.Nil(t, err, "baseDist.Run()")
+
+			files, err := ioutil.ReadDir(outDir)
+			assert.Nil(t, err, "check")
+			gotFileNames := make([]string, len(files))
+			for i, f := range files {
+				gotFileNames[i] = f.Name()
+			}
+			assert.ElementsMatch(t, tt.want, gotFileNames, "files")
+		})
+	}
+}
+
+func TestDistOutput_fileName(t *testing.T) {
+	tests := []struct {
+		name   string
+		output DistOutput
+		want   string
+	}{
+		{name: "default", output: DistOutput{BaseName: "CREDITS", legacy: true}, want: "CREDITS_linux_amd64"},
+		{name: "default with ext", output: DistOutput{BaseName: "CREDITS.txt", legacy: true}, want: "CREDITS.txt_linux_amd64"},
+		{name: "outputs", output: DistOutput{BaseName: "CREDITS"}, want: "CREDITS_linux_amd64"},
+		{name: "outputs with ext", output: DistOutput{BaseName: "CREDITS.json"}, want: "CREDITS_linux_amd64.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.output.fileName("linux_amd64")
+			assert.Equal(t, tt.want, got, "DistOutput.fileName()")
+			s, ok := tt.output.fileSuffix(got)
+			assert.True(t, ok, "DistOutput.fileSuffix()")
+			assert.Equal(t, "linux_amd64", s, "DistOutput.fileSuffix()")
+		})
+	}
+}
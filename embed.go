@@ -0,0 +1,111 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Modes of WriteEmbed.
+const (
+	// EmbedBytes writes the gzip compressed CREDITS as the byte slice in the source.
+	EmbedBytes = "bytes"
+	// EmbedFile writes the CREDITS file and the stub that reads it with `//go:embed`.
+	EmbedFile = "file"
+)
+
+// EmbedConfig configures WriteEmbed.
+type EmbedConfig struct {
+	// Package is the package name of the generated source. "main" is used if it is empty.
+	Package string
+	// Mode is EmbedBytes or EmbedFile. EmbedBytes is used if it is empty.
+	Mode string
+	// FileName is the name of the generated source. "credits_gen.go" is used if it is empty.
+	FileName string
+	// CreditsFile is the name of the CREDITS file in EmbedFile mode. "CREDITS" is used if it is empty.
+	CreditsFile string
+}
+
+func (c EmbedConfig) withDefault() EmbedConfig {
+	if c.Package == "" {
+		c.Package = "main"
+	}
+	if c.Mode == "" {
+		c.Mode = EmbedBytes
+	}
+	if c.FileName == "" {
+		c.FileName = "credits_gen.go"
+	}
+	if c.CreditsFile == "" {
+		c.CreditsFile = "CREDITS"
+	}
+	return c
+}
+
+const embedHeader = "// Code generated by go-ac. DO NOT EDIT.\n\n"
+
+// embedSource returns the Go source that registers credits to the runtime package(github.com/hankei6km/go-ac/credits).
+func embedSource(credits []byte, c EmbedConfig) ([]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteString(embedHeader)
+	fmt.Fprintf(b, "package %s\n\n", c.Package)
+	switch c.Mode {
+	case EmbedBytes:
+		z := &bytes.Buffer{}
+		gw := gzip.NewWriter(z) // Name, ModTime は空のまま(deterministic).
+		if _, err := gw.Write(credits); err != nil {
+			return nil, err
+		}
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+		b.WriteString("import \"github.com/hankei6km/go-ac/credits\"\n\n")
+		fmt.Fprintf(b, "func init() {\n\tcredits.RegisterGzip([]byte(%q))\n}\n", z.String())
+	case EmbedFile:
+		b.WriteString("import (\n\t_ \"embed\"\n\n\t\"github.com/hankei6km/go-ac/credits\"\n)\n\n")
+		fmt.Fprintf(b, "//go:embed %s\nvar creditsText []byte\n\n", c.CreditsFile)
+		b.WriteString("func init() {\n\tcredits.Register(creditsText)\n}\n")
+	default:
+		return nil, fmt.Errorf("unknown embed mode '%s'", c.Mode)
+	}
+	return format.Source(b.Bytes())
+}
+
+// WriteEmbed writes the Go source that embeds credits into dir.
+// The credits are read at runtime by the package github.com/hankei6km/go-ac/credits(ie. credits.Text()).
+func WriteEmbed(dir string, credits []byte, c EmbedConfig) error {
+	c = c.withDefault()
+	src, err := embedSource(credits, c)
+	if err != nil {
+		return wrapf(err, "WriteEmbed")
+	}
+	if c.Mode == EmbedFile {
+		if err := ioutil.WriteFile(filepath.Join(dir, c.CreditsFile), credits, 0644); err != nil {
+			return wrapf(err, "WriteEmbed")
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, c.FileName), src, 0644); err != nil {
+		return wrapf(err, "WriteEmbed")
+	}
+	return nil
+}
+
+// GenerateEmbed builds the Output, flushes it and writes the Go source that embeds the output into dir.
+// The OutStream of the OutputBuilder is replaced.
+func GenerateEmbed(ob OutputBuilder, dir string, c EmbedConfig) error {
+	b := &bytes.Buffer{}
+	if _, err := ob.Branch().OutStream(b).Build().Flush(); err != nil {
+		return wrapf(err, "GenerateEmbed")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return wrapf(err, "GenerateEmbed")
+	}
+	return WriteEmbed(dir, b.Bytes(), c)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hankei6km/go-ac/credits"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteEmbed(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	workDir := filepath.Join(cwd, "testdata", "work_embed")
+
+	tests := []struct {
+		name      string
+		config    EmbedConfig
+		wantFiles []string
+		wantPkg   string
+		wantErr   bool
+	}{
+		{
+			name:      "bytes",
+			config:    EmbedConfig{},
+			wantFiles: []string{"credits_gen.go"},
+			wantPkg:   "main",
+		}, {
+			name:      "file",
+			config:    EmbedConfig{Package: "cmd", Mode: EmbedFile, FileName: "zz_credits.go", CreditsFile: "LICENSES"},
+			wantFiles: []string{"LICENSES", "zz_credits.go"},
+			wantPkg:   "cmd",
+		}, {
+			name:    "unknown",
+			config:  EmbedConfig{Mode: "unknown"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			err = GenerateEmbed(
+				NewOutputBuilder().
+					GoSumFile(filepath.Join(cwd, "testdata", "goSum", "go.sum")).
+					WorkDir(workDir).
+					Binary(filepath.Join(cwd, "testdata", "distDir", "linux_amd64", "my_cmd")).
+					runFunc(func(argv []string, outStream, errStream io.Writer) error {
+						_, err := io.Copy(outStream, strings.NewReader("test"))
+						return err
+					}),
+				workDir, tt.config)
+			if tt.wantErr {
+				assert.NotNil(t, err, "GenerateEmbed()")
+				return
+			}
+			assert.Nil(t, err, "GenerateEmbed()")
+			for _, n := range tt.wantFiles {
+				_, err := os.Stat(filepath.Join(workDir, n))
+				assert.Nil(t, err, "GenerateEmbed()")
+			}
+
+			c := tt.config.withDefault()
+			f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(workDir, c.FileName), nil, parser.ParseComments)
+			assert.Nil(t, err, "parse the generated source")
+			assert.Equal(t, tt.wantPkg, f.Name.Name, "package")
+
+			src, err := ioutil.ReadFile(filepath.Join(workDir, c.FileName))
+			assert.Nil(t, err, "check")
+			switch c.Mode {
+			case EmbedBytes:
+				// 生成されたリテラルをそのまま credits に渡して確認.
+				s := string(src)
+				lit := s[strings.Index(s, "[]byte(")+len("[]byte(") : strings.LastIndex(s, "))")]
+				b, err := strconv.Unquote(lit)
+				assert.Nil(t, err, "check")
+				credits.RegisterGzip([]byte(b))
+				assert.Contains(t, credits.Text(), "test", "credits.Text()")
+			case EmbedFile:
+				assert.Contains(t, string(src), "//go:embed LICENSES", "stub")
+				b, err := ioutil.ReadFile(filepath.Join(workDir, c.CreditsFile))
+				assert.Nil(t, err, "check")
+				assert.Contains(t, string(b), "test", "CREDITS")
+			}
+		})
+	}
+}
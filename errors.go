@@ -1,18 +1,223 @@
-// +build go1.13
 // Copyright (c) 2019 hankei6km
 // Licensed under the MIT License. See LICENSE in the project root.
 
 package ac
 
-// errors.go と errors_compat.go は以下のソースコードの記事を参考に作成.
-// [サポートページ：WEB&#43;DB PRESS Vol.112：｜gihyo.jp … 技術評論社](https://gihyo.jp/magazine/wdpress/archive/2019/vol112/support)
-//  -「Goに入りては…… ── When In Go...」で使用されたソースコード
-
-// 1.13以前でも実行時エラーにならないようになっているが、
-// go version -m が実行できないので、実際には使うことはできない.
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
 
+// wrapf は以下のソースコードの記事を参考に作成.
+// [サポートページ：WEB&#43;DB PRESS Vol.112：｜gihyo.jp … 技術評論社](https://gihyo.jp/magazine/wdpress/archive/2019/vol112/support)
+//   - 「Goに入りては…… ── When In Go...」で使用されたソースコード
 func wrapf(err error, format string, a ...interface{}) error {
 	// return fmt.Errorf(format, a...)
 	return fmt.Errorf("%s: %w", fmt.Sprintf(format, a...), err)
 }
+
+var (
+	// ErrNoModules is returned when no dependent module is found in the binary.
+	ErrNoModules = errors.New("dependent module not found")
+	// ErrNotGoBinary is returned when the binary is not a Go executable(or it has no build info).
+	ErrNotGoBinary = errors.New("not a Go executable")
+	// ErrNoOutputs is returned from Dist.Run when no output file has been created.
+	ErrNoOutputs = errors.New("no output file has been created")
+	// ErrProgWithVendor is returned from Flush when both Prog and VendorDir are set.
+	ErrProgWithVendor = errors.New("prog can not be used with VendorDir")
+	// ErrUnsupportedFS is returned when the file system(FS) other than OSFS is used with the stage that
+	// reads or writes the files of the OS(Archive, Image and the archives in DistDir).
+	ErrUnsupportedFS = errors.New("the file system is not supported")
+)
+
+// errUnexpectedStderr is the error when the command has written to stderr without the exit code.
+var errUnexpectedStderr = errors.New("unexpected output to stderr")
+
+// notGoBinaryMessages are the messages of `go version -m` for the file that is not a Go executable.
+var notGoBinaryMessages = []string{
+	"could not read Go build info",
+	"not a Go executable",
+	"unrecognized file format",
+}
+
+// isNotGoBinaryError reports whether `go version -m` has run and reported that the file is not a Go executable.
+// The failures of running the command(ie. go is not found) are not.
+func isNotGoBinaryError(err error) bool {
+	var g *GeneratorError
+	if errors.As(err, &g) == false {
+		return false
+	}
+	var exitErr *exec.ExitError
+	if errors.As(g.Err, &exitErr) == false && errors.Is(g.Err, errUnexpectedStderr) == false {
+		return false
+	}
+	if strings.TrimSpace(g.Stderr) == "" {
+		// 実行可能ではないファイルは何も出力せずに終了する.
+		return true
+	}
+	for _, m := range notGoBinaryMessages {
+		if strings.Contains(g.Stderr, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// GeneratorError is the error of the command that is executed to generate the CREDITS file
+// (ie. `go version -m`, the generator program, gocredits.Run).
+// ExitCode is -1 if the command has not exited(or it is not a external program).
+type GeneratorError struct {
+	Cmd      string
+	Args     []string
+	Stderr   string
+	ExitCode int
+	Err      error
+}
+
+func (e *GeneratorError) Error() string {
+	s := fmt.Sprintf("execute %s %s: %v", e.Cmd, strings.Join(e.Args, " "), e.Err)
+	if e.Stderr != "" {
+		s += ": " + strings.TrimSpace(e.Stderr)
+	}
+	return s
+}
+
+func (e *GeneratorError) Unwrap() error {
+	return e.Err
+}
+
+func newGeneratorError(cmd string, args []string, stderr string, err error) *GeneratorError {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	return &GeneratorError{
+		Cmd:      cmd,
+		Args:     args,
+		Stderr:   stderr,
+		ExitCode: exitCode,
+		Err:      err,
+	}
+}
+
+// PruneError is the error in pruning go.sum.
+type PruneError struct {
+	GoSumFile string
+	Err       error
+}
+
+func (e *PruneError) Error() string {
+	return fmt.Sprintf("prune go.sum('%s'): %v", e.GoSumFile, e.Err)
+}
+
+func (e *PruneError) Unwrap() error {
+	return e.Err
+}
+
+// Stage is the stage of the pipeline that generates the CREDITS file.
+type Stage string
+
+// Stages of the pipeline.
+const (
+	StageModules  Stage = "modules"
+	StagePrune    Stage = "prune"
+	StageGenerate Stage = "generate"
+	StageArchive  Stage = "archive"
+)
+
+// StageError records the stage where the error has occurred.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+func stageErr(stage Stage, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StageError{Stage: stage, Err: err}
+}
+
+// PlatformError records the platform where the error has occurred in Dist.Run.
+type PlatformError struct {
+	Platform string
+	Binary   string
+	Stage    Stage
+	Err      error
+}
+
+func (e *PlatformError) Error() string {
+	return fmt.Sprintf("platform %s('%s') %s: %v", e.Platform, e.Binary, e.Stage, e.Err)
+}
+
+func (e *PlatformError) Unwrap() error {
+	return e.Err
+}
+
+func newPlatformError(platform, binary string, err error) *PlatformError {
+	e := &PlatformError{
+		Platform: platform,
+		Binary:   binary,
+		Err:      err,
+	}
+	var s *StageError
+	if errors.As(err, &s) {
+		e.Stage = s.Stage
+	}
+	return e
+}
+
+// RunError is the multi-error that is returned from Dist.Run in continue-on-error mode.
+// The files of the platforms those are not in RunError are written.
+// It is compatible with errors.Join (errors.Is and errors.As inspect each error).
+type RunError struct {
+	Errs []error
+}
+
+func (e *RunError) Error() string {
+	s := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+func (e *RunError) Unwrap() []error {
+	return e.Errs
+}
+
+// VendorMismatch is the module that is vendored at the different version.
+type VendorMismatch struct {
+	Module
+	Vendored string
+}
+
+// VendorError is the error that the modules in the binary are not matched to vendor/modules.txt.
+type VendorError struct {
+	// Missing are the modules that are not vendored.
+	Missing []Module
+	// Mismatched are the modules that are vendored at the different version.
+	Mismatched []VendorMismatch
+}
+
+func (e *VendorError) Error() string {
+	s := []string{}
+	for _, m := range e.Missing {
+		s = append(s, fmt.Sprintf("%s@%s is not vendored", m.Path, m.Version))
+	}
+	for _, m := range e.Mismatched {
+		s = append(s, fmt.Sprintf("%s@%s is vendored at %s", m.Path, m.Version, m.Vendored))
+	}
+	return "vendor: " + strings.Join(s, ", ")
+}
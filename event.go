@@ -0,0 +1,113 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// EventKind is the kind of Event.
+type EventKind string
+
+// Kinds of Event.
+const (
+	// EventPlatformDiscovered is fired for each platform directory in DistDir.
+	EventPlatformDiscovered EventKind = "PlatformDiscovered"
+	// EventModulesResolved is fired when the modules of the platform are resolved(Count).
+	EventModulesResolved EventKind = "ModulesResolved"
+	// EventGeneratorStarted is fired before the generator runs(Count is the number of platforms sharing the result).
+	EventGeneratorStarted EventKind = "GeneratorStarted"
+	// EventGeneratorFinished is fired after the generator runs(Duration, Hash).
+	EventGeneratorFinished EventKind = "GeneratorFinished"
+	// EventOutputWritten is fired when the file is written into OutDir(Path, Hash).
+	EventOutputWritten EventKind = "OutputWritten"
+	// EventMerged is fired when the outputs are merged by uniq(Path, Count).
+	EventMerged EventKind = "Merged"
+	// EventSkipped is fired when the platform is skipped by the error(Err).
+	EventSkipped EventKind = "Skipped"
+)
+
+// Event is fired while Dist runs.
+type Event struct {
+	Kind     EventKind
+	Platform string
+	Binary   string
+	Path     string
+	Count    int
+	Duration time.Duration
+	Hash     []byte
+	Err      error
+}
+
+func (e Event) attrs() []slog.Attr {
+	a := []slog.Attr{}
+	if e.Platform != "" {
+		a = append(a, slog.String("platform", e.Platform))
+	}
+	if e.Binary != "" {
+		a = append(a, slog.String("binary", e.Binary))
+	}
+	if e.Path != "" {
+		a = append(a, slog.String("path", e.Path))
+	}
+	if e.Count > 0 {
+		a = append(a, slog.Int("count", e.Count))
+	}
+	if e.Duration > 0 {
+		a = append(a, slog.Duration("duration", e.Duration))
+	}
+	if len(e.Hash) > 0 {
+		a = append(a, slog.String("hash", fmt.Sprintf("%x", e.Hash)))
+	}
+	if e.Err != nil {
+		a = append(a, slog.String("err", e.Err.Error()))
+	}
+	return a
+}
+
+func (e Event) String() string {
+	s := []string{string(e.Kind)}
+	for _, a := range e.attrs() {
+		s = append(s, a.String())
+	}
+	return strings.Join(s, " ")
+}
+
+// NewEventLogger returns the event hook that writes events into w.
+//
+//	ac.NewDistBuilder().OnEvent(ac.NewEventLogger(os.Stdout))
+func NewEventLogger(w io.Writer) func(Event) {
+	return func(e Event) {
+		fmt.Fprintf(w, "go-ac: %s\n", e)
+	}
+}
+
+// NewSlogEventHook returns the event hook that passes events to h.
+// The level of EventSkipped is Warn, others are Info.
+func NewSlogEventHook(h slog.Handler) func(Event) {
+	return func(e Event) {
+		level := slog.LevelInfo
+		if e.Kind == EventSkipped {
+			level = slog.LevelWarn
+		}
+		ctx := context.Background()
+		if h.Enabled(ctx, level) == false {
+			return
+		}
+		r := slog.NewRecord(time.Now(), level, string(e.Kind), 0)
+		r.AddAttrs(e.attrs()...)
+		h.Handle(ctx, r)
+	}
+}
+
+func (d *baseDist) fire(e Event) {
+	if d.onEvent != nil {
+		d.onEvent(e)
+	}
+}
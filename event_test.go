@@ -0,0 +1,134 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEventLogger(t *testing.T) {
+	got := &strings.Builder{}
+	l := NewEventLogger(got)
+	l(Event{Kind: EventModulesResolved, Platform: "linux_amd64", Count: 3})
+	l(Event{Kind: EventOutputWritten, Path: "CREDITS", Hash: []byte{0x01, 0xab}})
+	assert.Equal(t, `go-ac: ModulesResolved platform=linux_amd64 count=3
+go-ac: OutputWritten path=CREDITS hash=01ab
+`, got.String(), "NewEventLogger()")
+}
+
+func TestNewSlogEventHook(t *testing.T) {
+	got := &strings.Builder{}
+	h := slog.NewTextHandler(got, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := NewSlogEventHook(h)
+	l(Event{Kind: EventGeneratorFinished, Platform: "linux_amd64", Duration: time.Second})
+	l(Event{Kind: EventSkipped, Platform: "linux_arm64", Err: errors.New("fake error")})
+	assert.Equal(t, "level=WARN msg=Skipped platform=linux_arm64 err=\"fake error\"\n", got.String(), "NewSlogEventHook()")
+}
+
+func Test_baseDist_Run_OnEvent(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	err = ResetDir(outDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(outDir)
+
+	got := []string{}
+	err = NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		Dedup(true).
+		OnEvent(func(e Event) {
+			got = append(got, fmt.Sprintf("%s %s %d", e.Kind, e.Platform, e.Count))
+		}).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					_, err := io.Copy(outStream, strings.NewReader("test"))
+					return err
+				}),
+		).
+		Build().
+		Run()
+	assert.Nil(t, err, "baseDist.Run()")
+	assert.Equal(t, []string{
+		"PlatformDiscovered linux_386 0",
+		"PlatformDiscovered linux_amd64 0",
+		"PlatformDiscovered linux_amd64_v1 0",
+		"ModulesResolved linux_386 1",
+		"ModulesResolved linux_amd64 1",
+		"ModulesResolved linux_amd64_v1 1",
+		"Merged  3",
+		"GeneratorStarted linux_386 3",
+		"GeneratorFinished linux_386 0",
+		"OutputWritten  0",
+	}, got, "events")
+}
+
+func Test_baseDist_Run_NoEvent(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	err = ResetDir(outDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(outDir)
+
+	// OnEvent が指定されていなければ何も出力しない.
+	got := &strings.Builder{}
+	err = NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		OutStream(got).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					_, err := io.Copy(outStream, strings.NewReader("test"))
+					return err
+				}),
+		).
+		Build().
+		Run()
+	assert.Nil(t, err, "baseDist.Run()")
+	assert.Equal(t, "", got.String(), "OutStream")
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// WorkspacePattern is Pattern of Exclusion for the modules in the workspace(the use modules of go.work).
+const WorkspacePattern = "go.work"
+
+// Exclusion is the module that is excluded from CREDITS.
+type Exclusion struct {
+	Module
+	// Pattern is the pattern that matches the module, or WorkspacePattern.
+	Pattern string `json:"pattern"`
+}
+
+// ParsePatterns splits the comma-separated list of glob patterns(same as GOPRIVATE).
+func ParsePatterns(s string) []string {
+	ret := []string{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// PrivatePatterns returns the patterns in GOPRIVATE and GONOSUMDB.
+func PrivatePatterns() []string {
+	ret := []string{}
+	seen := map[string]bool{}
+	for _, e := range []string{"GOPRIVATE", "GONOSUMDB"} {
+		for _, p := range ParsePatterns(os.Getenv(e)) {
+			if seen[p] == false {
+				seen[p] = true
+				ret = append(ret, p)
+			}
+		}
+	}
+	return ret
+}
+
+// MatchPrefixPattern reports whether the pattern matches a prefix of target
+// in the same way as GOPRIVATE.
+// (ie. "git.corp.example" matches "git.corp.example/foo/bar", "*.corp.example" matches "git.corp.example/foo")
+func MatchPrefixPattern(pattern, target string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	n := strings.Count(pattern, "/")
+	prefix := target
+	for i := 0; i < len(target); i++ {
+		if target[i] == '/' {
+			if n == 0 {
+				prefix = target[:i]
+				break
+			}
+			n--
+		}
+	}
+	if n > 0 {
+		// pattern has more elements than target.
+		return false
+	}
+	matched, _ := path.Match(pattern, prefix)
+	return matched
+}
+
+// excludeModules splits mods into modules that are not excluded and exclusions.
+func excludeModules(patterns []string, mods []Module) ([]Module, []Exclusion) {
+	rest := []Module{}
+	excluded := []Exclusion{}
+	for _, m := range mods {
+		found := false
+		for _, p := range patterns {
+			if MatchPrefixPattern(p, m.Path) {
+				excluded = append(excluded, Exclusion{Module: m, Pattern: p})
+				found = true
+				break
+			}
+		}
+		if found == false {
+			rest = append(rest, m)
+		}
+	}
+	return rest, excluded
+}
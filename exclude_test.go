@@ -0,0 +1,111 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPrefixPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"git.corp.example", "git.corp.example/foo/bar", true},
+		{"git.corp.example", "git.corp.example", true},
+		{"git.corp.example/", "git.corp.example/foo", true},
+		{"*.corp.example", "git.corp.example/foo", true},
+		{"git.corp.example/foo", "git.corp.example/foo/bar", true},
+		{"git.corp.example/foo", "git.corp.example/foobar", false},
+		{"git.corp.example/*/bar", "git.corp.example/foo/bar/baz", true},
+		{"git.corp.example/foo/bar", "git.corp.example/foo", false},
+		{"corp.example", "git.corp.example/foo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"_"+tt.target, func(t *testing.T) {
+			assert.Equal(t, tt.want, MatchPrefixPattern(tt.pattern, tt.target), "MatchPrefixPattern()")
+		})
+	}
+}
+
+func TestPrivatePatterns(t *testing.T) {
+	t.Setenv("GOPRIVATE", "git.corp.example, *.internal.example")
+	t.Setenv("GONOSUMDB", "git.corp.example,example.com/private")
+	assert.Equal(t,
+		[]string{"git.corp.example", "*.internal.example", "example.com/private"},
+		PrivatePatterns(),
+		"PrivatePatterns()",
+	)
+}
+
+func Test_excludeModules(t *testing.T) {
+	mods := []Module{
+		{Path: "git.corp.example/foo", Version: "v1.0.0"},
+		{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"},
+	}
+	rest, excluded := excludeModules([]string{"*.corp.example"}, mods)
+	assert.Equal(t, []Module{mods[1]}, rest, "rest")
+	assert.Equal(t, []Exclusion{{Module: mods[0], Pattern: "*.corp.example"}}, excluded, "excluded")
+}
+
+func Test_baseDist_Run_With_Exclude(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	err = ResetDir(outDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(outDir)
+
+	err = NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		ReplaceOs([][]string{
+			[]string{"linux", "Linux"},
+		}).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				Exclude([]string{"gopkg.in"}).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					_, err := io.Copy(outStream, strings.NewReader("test"))
+					return err
+				}),
+		).
+		Build().
+		Run()
+	assert.Nil(t, err, "baseDist.Run()")
+
+	b, err := ioutil.ReadFile(filepath.Join(outDir, "CREDITS.excluded.json"))
+	assert.Nil(t, err, "check")
+	got := struct {
+		Excluded []DistExclusions `json:"excluded"`
+	}{}
+	err = json.Unmarshal(b, &got)
+	assert.Nil(t, err, "check")
+	m := []Exclusion{{Module: Module{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"}, Pattern: "gopkg.in"}}
+	assert.ElementsMatch(t, []DistExclusions{
+		{Platform: "Linux_386", Binary: filepath.Join(distDir, "linux_386", "my_cmd"), Modules: m},
+		{Platform: "Linux_amd64", Binary: filepath.Join(distDir, "linux_amd64", "my_cmd"), Modules: m},
+		{Platform: "Linux_amd64_v1", Binary: filepath.Join(distDir, "linux_amd64_v1", "my_cmd"), Modules: m},
+	}, got.Excluded, "excluded")
+}
@@ -0,0 +1,433 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WritableFS is the file system that go-ac reads from(fs.FS) and writes into.
+// The names are the paths of the OS(ie. filepath.Join(outDir, "CREDITS")) instead of
+// the slash-separated paths of fs.FS.
+type WritableFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// osFS implements WritableFS by the file system of the OS.
+type osFS struct{}
+
+// OSFS returns WritableFS of the file system of the OS(the default).
+func OSFS() WritableFS {
+	return osFS{}
+}
+
+// isOSFS reports whether fsys is the file system of the OS.
+func isOSFS(fsys fs.FS) bool {
+	_, ok := fsys.(osFS)
+	return ok
+}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (osFS) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+// MemFS implements WritableFS in memory.
+// The directories are created by MkdirAll(the root always exists), WriteFile needs the parent directory.
+// The external programs(`go version -m`) can not read the files in MemFS.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+// memFile is the file(or the directory) in MemFS.
+type memFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// memRoot is the root directory of MemFS.
+var memRoot = &memFile{mode: fs.ModeDir | 0755}
+
+// NewMemFS returns the empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string]*memFile{}}
+}
+
+// memKey returns the key of name in MemFS(unrooted slash-separated path).
+func memKey(name string) string {
+	k := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "/")
+	if k == "" {
+		return "."
+	}
+	return k
+}
+
+// memParent returns the key of the parent directory.
+func memParent(key string) string {
+	return path.Dir(key)
+}
+
+// memInfo implements fs.FileInfo and fs.DirEntry of memFile.
+type memInfo struct {
+	name string
+	f    *memFile
+}
+
+func (i *memInfo) Name() string               { return i.name }
+func (i *memInfo) Size() int64                { return int64(len(i.f.data)) }
+func (i *memInfo) Mode() fs.FileMode          { return i.f.mode }
+func (i *memInfo) ModTime() time.Time         { return i.f.modTime }
+func (i *memInfo) IsDir() bool                { return i.f.mode.IsDir() }
+func (i *memInfo) Sys() interface{}           { return nil }
+func (i *memInfo) Type() fs.FileMode          { return i.f.mode.Type() }
+func (i *memInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// memReader is the opened regular file(it also implements io.ReaderAt and io.Seeker).
+type memReader struct {
+	*bytes.Reader
+	info *memInfo
+}
+
+func (r *memReader) Stat() (fs.FileInfo, error) { return r.info, nil }
+func (r *memReader) Close() error               { return nil }
+
+// memDir is the opened directory.
+type memDir struct {
+	info    *memInfo
+	entries []fs.DirEntry
+	off     int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.off:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.off += len(rest)
+	return rest, nil
+}
+
+// lookup returns the file of key(the caller must hold mu).
+func (m *MemFS) lookup(key string) (*memFile, bool) {
+	if key == "." {
+		return memRoot, true
+	}
+	f, ok := m.files[key]
+	return f, ok
+}
+
+// isDir reports whether key is the directory(the caller must hold mu).
+func (m *MemFS) isDir(key string) bool {
+	f, ok := m.lookup(key)
+	return ok && f.mode.IsDir()
+}
+
+// entries returns the entries of the directory key sorted by name(the caller must hold mu).
+func (m *MemFS) entries(key string) []fs.DirEntry {
+	ret := []fs.DirEntry{}
+	for k, f := range m.files {
+		if memParent(k) == key {
+			ret = append(ret, &memInfo{name: path.Base(k), f: f})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name() < ret[j].Name() })
+	return ret
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memKey(name)
+	f, ok := m.lookup(k)
+	if ok == false {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info := &memInfo{name: path.Base(k), f: f}
+	if f.mode.IsDir() {
+		return &memDir{info: info, entries: m.entries(k)}, nil
+	}
+	return &memReader{Reader: bytes.NewReader(f.data), info: info}, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.lookup(memKey(name))
+	switch {
+	case ok == false:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	case f.mode.IsDir():
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]byte{}, f.data...), nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memKey(name)
+	f, ok := m.lookup(k)
+	switch {
+	case ok == false:
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	case f.mode.IsDir() == false:
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return m.entries(k), nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memKey(name)
+	f, ok := m.lookup(k)
+	if ok == false {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memInfo{name: path.Base(k), f: f}, nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memKey(name)
+	if m.isDir(memParent(k)) == false || m.isDir(k) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+	}
+	m.files[k] = &memFile{data: append([]byte{}, data...), mode: perm, modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, n := memKey(oldname), memKey(newname)
+	f, ok := m.files[o]
+	if ok == false || f.mode.IsDir() {
+		// ディレクトリの rename は今回は使わない.
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if m.isDir(memParent(n)) == false {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrNotExist}
+	}
+	delete(m.files, o)
+	m.files[n] = f
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memKey(name)
+	if _, ok := m.files[k]; ok == false {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if len(m.entries(k)) > 0 {
+		// 空ではないディレクトリ.
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	delete(m.files, k)
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := memKey(name); k != "."; k = memParent(k) {
+		if f, ok := m.files[k]; ok {
+			if f.mode.IsDir() == false {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+			}
+			continue
+		}
+		m.files[k] = &memFile{mode: fs.ModeDir | perm, modTime: time.Now()}
+	}
+	return nil
+}
+
+// Files returns the names of the regular files in MemFS(sorted, slash-separated without the leading "/").
+func (m *MemFS) Files() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ret := []string{}
+	for k, f := range m.files {
+		if f.mode.IsDir() == false {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// removeAllFS removes name and its children from fsys(like os.RemoveAll).
+func removeAllFS(fsys WritableFS, name string) error {
+	if isOSFS(fsys) {
+		return os.RemoveAll(name)
+	}
+	s, err := fs.Stat(fsys, name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	if s.IsDir() {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := removeAllFS(fsys, filepath.Join(name, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return fsys.Remove(name)
+}
+
+// tempName returns the name of the temporary file in dir(like ioutil.TempFile).
+func tempName(dir, pattern string) string {
+	r := strconv.FormatUint(uint64(rand.Uint32()), 10)
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		return filepath.Join(dir, pattern[:i]+r+pattern[i+1:])
+	}
+	return filepath.Join(dir, pattern+r)
+}
+
+// tempWriter is implemented by WritableFS that can create the temporary file exclusively.
+type tempWriter interface {
+	writeTemp(dir, pattern string, data []byte, perm fs.FileMode) (string, error)
+}
+
+// writeTempFS writes data into the new temporary file in dir, and returns its name.
+// The existing file is never overwritten(like O_EXCL of ioutil.TempFile).
+func writeTempFS(fsys WritableFS, dir, pattern string, data []byte, perm fs.FileMode) (string, error) {
+	if t, ok := fsys.(tempWriter); ok {
+		return t.writeTemp(dir, pattern, data, perm)
+	}
+	for i := 0; i < 10000; i++ {
+		name := tempName(dir, pattern)
+		_, err := fs.Stat(fsys, name)
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, fs.ErrNotExist) == false:
+			return "", err
+		}
+		return name, fsys.WriteFile(name, data, perm)
+	}
+	return "", &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, pattern), Err: fs.ErrExist}
+}
+
+func (osFS) writeTemp(dir, pattern string, data []byte, perm fs.FileMode) (name string, err error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(data); err != nil {
+		return "", err
+	}
+	if err := f.Chmod(perm); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (m *MemFS) writeTemp(dir, pattern string, data []byte, perm fs.FileMode) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isDir(memKey(dir)) == false {
+		return "", &fs.PathError{Op: "createtemp", Path: dir, Err: fs.ErrNotExist}
+	}
+	for i := 0; i < 10000; i++ {
+		name := tempName(dir, pattern)
+		k := memKey(name)
+		if _, ok := m.files[k]; ok {
+			continue
+		}
+		m.files[k] = &memFile{data: append([]byte{}, data...), mode: perm, modTime: time.Now()}
+		return name, nil
+	}
+	return "", &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, pattern), Err: fs.ErrExist}
+}
+
+// isGoExecutableFS reports whether the file in fsys is a Go executable.
+func isGoExecutableFS(fsys fs.FS, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if r, ok := f.(io.ReaderAt); ok {
+		_, err := buildinfo.Read(r)
+		return err == nil
+	}
+	_, _, ok := readGoExecutable(f)
+	return ok
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	root := filepath.Join(string(filepath.Separator), "work")
+
+	err := m.WriteFile(filepath.Join(root, "a"), []byte("a"), 0644)
+	assert.ErrorIs(t, err, fs.ErrNotExist, "WriteFile(no parent)")
+
+	err = m.MkdirAll(filepath.Join(root, "out"), os.ModePerm)
+	assert.Nil(t, err, "MkdirAll()")
+	err = m.WriteFile(filepath.Join(root, "out", "a"), []byte("a"), 0644)
+	assert.Nil(t, err, "WriteFile()")
+	b, err := fs.ReadFile(m, filepath.Join(root, "out", "a"))
+	assert.Nil(t, err, "ReadFile()")
+	assert.Equal(t, "a", string(b), "ReadFile()")
+
+	err = m.Rename(filepath.Join(root, "out", "a"), filepath.Join(root, "out", "b"))
+	assert.Nil(t, err, "Rename()")
+	_, err = fs.Stat(m, filepath.Join(root, "out", "a"))
+	assert.ErrorIs(t, err, fs.ErrNotExist, "Rename()")
+	err = m.Rename(filepath.Join(root, "out", "b"), filepath.Join(root, "foo", "b"))
+	assert.ErrorIs(t, err, fs.ErrNotExist, "Rename(no parent)")
+
+	entries, err := fs.ReadDir(m, filepath.Join(root, "out"))
+	assert.Nil(t, err, "ReadDir()")
+	assert.Equal(t, 1, len(entries), "ReadDir()")
+	assert.Equal(t, "b", entries[0].Name(), "ReadDir()")
+	assert.Equal(t, []string{"work/out/b"}, m.Files(), "Files()")
+
+	err = m.Remove(filepath.Join(root, "out"))
+	assert.NotNil(t, err, "Remove(dir)")
+	err = m.Remove(filepath.Join(root, "out", "b"))
+	assert.Nil(t, err, "Remove()")
+	err = m.Remove(filepath.Join(root, "out", "b"))
+	assert.ErrorIs(t, err, fs.ErrNotExist, "Remove()")
+}
+
+func Test_baseDist_Run_With_MemFS(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	goSumDir := filepath.Join(testDir, "goSum")
+	// outDir と workDir は MemFS にのみ存在する.
+	outDir := filepath.Join(testDir, "mem_out")
+	workDir := filepath.Join(testDir, "mem_work")
+
+	m := NewMemFS()
+	for _, d := range []string{outDir, workDir} {
+		err := m.MkdirAll(d, os.ModePerm)
+		assert.Nil(t, err, "check")
+	}
+	// バイナリは `go version -m` が読むので、OS 上と同じパスに置く.
+	for _, p := range []string{"linux_386", "linux_amd64", "linux_amd64_v1"} {
+		b, err := ioutil.ReadFile(filepath.Join(distDir, p, "my_cmd"))
+		assert.Nil(t, err, "check")
+		err = m.MkdirAll(filepath.Join(distDir, p), os.ModePerm)
+		assert.Nil(t, err, "check")
+		err = m.WriteFile(filepath.Join(distDir, p, "my_cmd"), b, 0755)
+		assert.Nil(t, err, "check")
+	}
+	err = m.MkdirAll(goSumDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	err = m.WriteFile(filepath.Join(goSumDir, "go.sum"), []byte(
+		"gopkg.in/yaml.v2 v2.2.2 h1:ZCJp+EgiOT7lHqUV2J862kp8Qj64Jo6az82+3Td9dZw=\n"+
+			"gopkg.in/yaml.v2 v2.2.2/go.mod h1:hI93XBmqTisBFMUTm0b8Fm+jr3Dg1NNxqwp+5A1VGuI=\n"), 0644)
+	assert.Nil(t, err, "check")
+	// 前回の実行で書き出されたファイル.
+	err = m.WriteFile(filepath.Join(outDir, "CREDITS_windows_386"), []byte("stale"), 0644)
+	assert.Nil(t, err, "check")
+
+	goSum, genDir := "", ""
+	err = NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		Uniq(false).
+		FS(m).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					// 生成器は OS の一時ディレクトリにコピーされた go.sum を読む.
+					genDir = argv[0]
+					b, err := ioutil.ReadFile(filepath.Join(argv[0], "go.sum"))
+					if err != nil {
+						return err
+					}
+					goSum = string(b)
+					_, err = io.Copy(outStream, strings.NewReader("test"))
+					return err
+				}),
+		).
+		Build().
+		Run()
+	assert.Nil(t, err, "baseDist.Run()")
+	assert.Contains(t, goSum, "gopkg.in/yaml.v2 v2.2.2 ", "pruned go.sum")
+	assert.NotEqual(t, workDir, genDir, "generator dir")
+	_, err = os.Stat(genDir)
+	assert.True(t, os.IsNotExist(err), "generator dir is removed")
+
+	files, err := fs.ReadDir(m, outDir)
+	assert.Nil(t, err, "check")
+	gotFileNames := make([]string, len(files))
+	for i, f := range files {
+		gotFileNames[i] = f.Name()
+	}
+	assert.ElementsMatch(t, []string{"CREDITS_linux_386", "CREDITS_linux_amd64", "CREDITS_linux_amd64_v1"}, gotFileNames, "files")
+	b, err := fs.ReadFile(m, filepath.Join(outDir, "CREDITS_linux_386"))
+	assert.Nil(t, err, "check")
+	assert.Equal(t, "test", string(b), "content")
+
+	for _, d := range []string{outDir, workDir} {
+		_, err := os.Stat(d)
+		assert.True(t, os.IsNotExist(err), "not written to the OS")
+	}
+}
+
+// plainFS hides writeTemp of WritableFS.
+type plainFS struct {
+	WritableFS
+}
+
+func Test_writeTempFS(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	workDir := filepath.Join(cwd, "testdata", "work_fs")
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	m := NewMemFS()
+	err = m.MkdirAll(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	tests := []struct {
+		name string
+		fsys WritableFS
+	}{
+		{name: "OSFS", fsys: OSFS()},
+		{name: "MemFS", fsys: m},
+		{name: "other", fsys: plainFS{NewMemFS()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if p, ok := tt.fsys.(plainFS); ok {
+				err := p.MkdirAll(workDir, os.ModePerm)
+				assert.Nil(t, err, "check")
+			}
+			names := map[string]bool{}
+			for i := 0; i < 10; i++ {
+				name, err := writeTempFS(tt.fsys, workDir, ".CREDITS_*.tmp", []byte("test"), 0644)
+				assert.Nil(t, err, "writeTempFS()")
+				assert.Equal(t, workDir, filepath.Dir(name), "writeTempFS()")
+				assert.False(t, names[name], "writeTempFS() returns the new file")
+				names[name] = true
+				s, err := fs.Stat(tt.fsys, name)
+				assert.Nil(t, err, "check")
+				assert.Equal(t, fs.FileMode(0644), s.Mode().Perm(), "writeTempFS() mode")
+				b, err := fs.ReadFile(tt.fsys, name)
+				assert.Nil(t, err, "check")
+				assert.Equal(t, "test", string(b), "writeTempFS() content")
+			}
+			_, err := writeTempFS(tt.fsys, filepath.Join(workDir, "foo"), ".CREDITS_*.tmp", []byte("test"), 0644)
+			assert.NotNil(t, err, "writeTempFS(no dir)")
+		})
+	}
+}
+
+func Test_progOutput_Flush_With_MemFS(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	goSumDir := filepath.Join(testDir, "goSum")
+	workDir := filepath.Join(testDir, "mem_work")
+
+	m := NewMemFS()
+	for _, d := range []string{workDir, goSumDir} {
+		err := m.MkdirAll(d, os.ModePerm)
+		assert.Nil(t, err, "check")
+	}
+	err = m.WriteFile(filepath.Join(goSumDir, "go.sum"), []byte(
+		"gopkg.in/yaml.v2 v2.2.2 h1:ZCJp+EgiOT7lHqUV2J862kp8Qj64Jo6az82+3Td9dZw=\n"), 0644)
+	assert.Nil(t, err, "check")
+
+	r := &RecordingRunner{Runner: testFakeRunner()}
+	out := &strings.Builder{}
+	_, err = NewOutputBuilder().
+		WorkDir(workDir).
+		Binary(filepath.Join(testDir, "binDir", "my_cmd")).
+		GoSumFile(filepath.Join(goSumDir, "go.sum")).
+		FS(m).
+		Prog("gocredits").
+		Runner(r).
+		OutStream(out).
+		Build().
+		Flush()
+	assert.Nil(t, err, "progOutput.Flush()")
+
+	// Prog は OS の一時ディレクトリにコピーされた go.sum を読む.
+	cmds := r.Commands()
+	genDir := cmds[len(cmds)-1].Args[0]
+	assert.Equal(t, "credits: "+genDir, out.String(), "progOutput.Flush()")
+	assert.NotEqual(t, workDir, genDir, "generator dir")
+	_, err = os.Stat(genDir)
+	assert.True(t, os.IsNotExist(err), "generator dir is removed")
+	_, err = os.Stat(workDir)
+	assert.True(t, os.IsNotExist(err), "not written to the OS")
+}
+
+func Test_ErrUnsupportedFS(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	workDir := filepath.Join(testDir, "mem_work")
+	outDir := filepath.Join(testDir, "mem_out")
+
+	m := NewMemFS()
+	for _, d := range []string{outDir, workDir} {
+		err := m.MkdirAll(d, os.ModePerm)
+		assert.Nil(t, err, "check")
+	}
+	err = NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		FS(m).
+		Archive(ArchiveConfig{Format: ArchiveTarGz}).
+		Build().
+		Run()
+	assert.ErrorIs(t, err, ErrUnsupportedFS, "baseDist.Run()")
+}
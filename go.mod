@@ -1,9 +1,14 @@
 module github.com/hankei6km/go-ac
 
-go 1.13
+go 1.21
 
 require (
 	github.com/Songmu/gocredits v0.3.0
-	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.9.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,161 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parseGoWorkUse returns the directories in the use directives of go.work.
+func parseGoWorkUse(fsys fs.FS, name string) ([]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dirs := []string{}
+	inBlock := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if i := strings.Index(l, "//"); i >= 0 {
+			l = l[:i]
+		}
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+			continue
+		case inBlock && l == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case l == "use (" || l == "use(":
+			inBlock = true
+			continue
+		case strings.HasPrefix(l, "use ") || strings.HasPrefix(l, "use\t"):
+			l = strings.TrimSpace(l[len("use"):])
+		default:
+			continue
+		}
+		if u, err := strconv.Unquote(l); err == nil {
+			l = u
+		}
+		dirs = append(dirs, l)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+// GoWorkSumFiles returns go.sum files of the modules in the workspace and go.work.sum.
+// The files that do not exist are not included.
+func GoWorkSumFiles(goWork string) ([]string, error) {
+	return goWorkSumFiles(OSFS(), goWork)
+}
+
+func goWorkSumFiles(fsys fs.FS, goWork string) ([]string, error) {
+	dirs, err := parseGoWorkUse(fsys, goWork)
+	if err != nil {
+		return nil, wrapf(err, "GoWorkSumFiles")
+	}
+	base := filepath.Dir(goWork)
+	candidates := []string{}
+	for _, d := range dirs {
+		if filepath.IsAbs(d) == false {
+			d = filepath.Join(base, d)
+		}
+		candidates = append(candidates, filepath.Join(d, "go.sum"))
+	}
+	candidates = append(candidates, goWork+".sum")
+
+	ret := []string{}
+	for _, c := range candidates {
+		if s, err := fs.Stat(fsys, c); err == nil && s.Mode().IsRegular() {
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
+
+// goWorkModules returns the module paths in go.mod of the use directories of go.work.
+// The directories that have no go.mod are skipped.
+func goWorkModules(fsys fs.FS, goWork string) (map[string]bool, error) {
+	dirs, err := parseGoWorkUse(fsys, goWork)
+	if err != nil {
+		return nil, wrapf(err, "goWorkModules")
+	}
+	base := filepath.Dir(goWork)
+	ret := map[string]bool{}
+	for _, d := range dirs {
+		if filepath.IsAbs(d) == false {
+			d = filepath.Join(base, d)
+		}
+		p, err := readModulePath(fsys, filepath.Join(d, "go.mod"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, wrapf(err, "goWorkModules")
+		}
+		ret[p] = true
+	}
+	return ret, nil
+}
+
+// readModulePath returns the path in the module directive of go.mod.
+func readModulePath(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if i := strings.Index(l, "//"); i >= 0 {
+			l = l[:i]
+		}
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "module ") || strings.HasPrefix(l, "module\t") {
+			l = strings.TrimSpace(l[len("module"):])
+			if u, err := strconv.Unquote(l); err == nil {
+				l = u
+			}
+			return l, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("readModulePath '%s': the module directive is not found", name)
+}
+
+// excludeWorkspace splits mods into modules that are not in the workspace(the use modules of go.work) and exclusions.
+// The modules in the workspace are the first-party modules, they are built as (devel).
+func (c *baseOutput) excludeWorkspace(mods []Module) ([]Module, []Exclusion, error) {
+	if c.goWork == "" {
+		return mods, []Exclusion{}, nil
+	}
+	ws, err := goWorkModules(c.fs, c.goWork)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest := []Module{}
+	excluded := []Exclusion{}
+	for _, m := range mods {
+		if m.Version == "(devel)" && ws[m.Path] {
+			excluded = append(excluded, Exclusion{Module: m, Pattern: WorkspacePattern})
+			continue
+		}
+		rest = append(rest, m)
+	}
+	return rest, excluded, nil
+}
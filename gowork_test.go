@@ -0,0 +1,126 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseGoWorkUse(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	workDir := filepath.Join(cwd, "testdata", "work_gowork")
+	tests := []struct {
+		name string
+		work string
+		want []string
+	}{
+		{
+			name: "block",
+			work: "go 1.21\n\nuse (\n\t./a\n\t\"./b\" // comment\n)\n",
+			want: []string{"./a", "./b"},
+		}, {
+			name: "line",
+			work: "go 1.21\n\nuse ./a\nuse /abs/b\n\nreplace example.com/foo => ./foo\n",
+			want: []string{"./a", "/abs/b"},
+		}, {
+			name: "none",
+			work: "go 1.21\n",
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+			goWork := filepath.Join(workDir, "go.work")
+			err = ioutil.WriteFile(goWork, []byte(tt.work), 0644)
+			assert.Nil(t, err, "check")
+
+			got, err := parseGoWorkUse(OSFS(), goWork)
+			assert.Nil(t, err, "parseGoWorkUse()")
+			assert.Equal(t, tt.want, got, "parseGoWorkUse()")
+		})
+	}
+}
+
+func TestGoWorkSumFiles(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	goWorkDir := filepath.Join(cwd, "testdata", "goWork")
+
+	got, err := GoWorkSumFiles(filepath.Join(goWorkDir, "go.work"))
+	assert.Nil(t, err, "GoWorkSumFiles()")
+	assert.Equal(t, []string{
+		filepath.Join(goWorkDir, "a", "go.sum"),
+		filepath.Join(goWorkDir, "b", "go.sum"),
+		filepath.Join(goWorkDir, "go.work.sum"),
+	}, got, "GoWorkSumFiles()")
+
+	_, err = GoWorkSumFiles(filepath.Join(goWorkDir, "foo"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "GoWorkSumFiles()")
+}
+
+func Test_baseOutput_resolve_GoWork(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	goWorkDir := filepath.Join(cwd, "testdata", "goWork")
+	runner := RunnerFunc(func(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		_, err := io.WriteString(stdout, "my_cmd: go1.21.0\n\tpath\texample.com/my_cmd\n\tmod\texample.com/my_cmd\t(devel)\t\n"+
+			"\tdep\texample.com/a\t(devel)\t\n"+
+			"\tdep\texample.com/b\t(devel)\t\n"+
+			"\tdep\texample.com/local\t(devel)\t\n"+
+			"\tdep\tgopkg.in/yaml.v2\tv2.2.2\th1:ZCJp+EgiOT7lHqUV2J862kp8Qj64Jo6az82+3Td9dZw=\n")
+		return err
+	})
+	tests := []struct {
+		name         string
+		goWork       string
+		wantGen      []Module
+		wantExcluded []Exclusion
+	}{
+		{
+			name:   "go.work",
+			goWork: filepath.Join(goWorkDir, "go.work"),
+			wantGen: []Module{
+				{Path: "example.com/local", Version: "(devel)"},
+				{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"},
+			},
+			wantExcluded: []Exclusion{
+				{Module: Module{Path: "example.com/a", Version: "(devel)"}, Pattern: WorkspacePattern},
+				{Module: Module{Path: "example.com/b", Version: "(devel)"}, Pattern: WorkspacePattern},
+			},
+		}, {
+			name: "without go.work",
+			wantGen: []Module{
+				{Path: "example.com/a", Version: "(devel)"},
+				{Path: "example.com/b", Version: "(devel)"},
+				{Path: "example.com/local", Version: "(devel)"},
+				{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"},
+			},
+			wantExcluded: []Exclusion{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewOutputBuilder().Binary("my_cmd").Runner(runner).runFunc(nil)
+			if tt.goWork != "" {
+				b = b.GoWork(tt.goWork)
+			}
+			c := b.Build().(*baseOutput)
+			err := c.resolve()
+			assert.Nil(t, err, "baseOutput.resolve()")
+			assert.Equal(t, tt.wantGen, c.generated, "baseOutput.resolve() generated")
+			assert.Equal(t, tt.wantExcluded, c.Excluded(), "baseOutput.Excluded()")
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"regexp"
+)
+
+// Hasher is the hash algorithm that is used to hash(and uniq) the outputs.
+type Hasher struct {
+	// Name is the name of the algorithm in the digest(ie. "sha256").
+	Name string
+	New  func() hash.Hash
+}
+
+// SHA256 is the default Hasher.
+var SHA256 = Hasher{Name: "sha256", New: sha256.New}
+
+func (h Hasher) withDefault() Hasher {
+	if h.New == nil {
+		return SHA256
+	}
+	return h
+}
+
+// Sum returns the hash of b.
+func (h Hasher) Sum(b []byte) []byte {
+	hh := h.withDefault().New()
+	hh.Write(b)
+	return hh.Sum(nil)
+}
+
+// Digest returns the digest of the hash in the form of `<name>:<hex>`(ie. sha256:e3b0c442...).
+func (h Hasher) Digest(sum []byte) string {
+	return h.withDefault().Name + ":" + hex.EncodeToString(sum)
+}
+
+// Normalizer normalizes the output before it is hashed.
+// It is used to ignore platform-specific or non-deterministic lines(ie. timestamps, the path of WorkDir).
+// The written files are not normalized.
+type Normalizer func(b []byte) []byte
+
+// DropLines returns Normalizer that removes the lines matched by any of res.
+func DropLines(res ...*regexp.Regexp) Normalizer {
+	return func(b []byte) []byte {
+		lines := bytes.SplitAfter(b, []byte("\n"))
+		ret := make([]byte, 0, len(b))
+		for _, l := range lines {
+			drop := false
+			for _, re := range res {
+				if re.Match(bytes.TrimSuffix(l, []byte("\n"))) {
+					drop = true
+					break
+				}
+			}
+			if drop == false {
+				ret = append(ret, l...)
+			}
+		}
+		return ret
+	}
+}
+
+// ReplaceRegexp returns Normalizer that replaces the matches of re with repl(see regexp.ReplaceAll).
+func ReplaceRegexp(re *regexp.Regexp, repl string) Normalizer {
+	return func(b []byte) []byte {
+		return re.ReplaceAll(b, []byte(repl))
+	}
+}
+
+// Normalizers returns Normalizer that applies ns in order.
+func Normalizers(ns ...Normalizer) Normalizer {
+	return func(b []byte) []byte {
+		for _, n := range ns {
+			b = n(b)
+		}
+		return b
+	}
+}
+
+// normalizedSum returns the hash of b normalized by n(nil is not normalized).
+func normalizedSum(h Hasher, n Normalizer, b []byte) []byte {
+	if n != nil {
+		b = n(b)
+	}
+	return h.Sum(b)
+}
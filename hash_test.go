@@ -0,0 +1,72 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"crypto/sha1"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasher_Digest(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+		in     string
+		want   string
+	}{
+		{
+			name:   "default",
+			hasher: Hasher{},
+			in:     "",
+			want:   "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		}, {
+			name:   "sha1",
+			hasher: Hasher{Name: "sha1", New: sha1.New},
+			in:     "",
+			want:   "sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.hasher.Digest(tt.hasher.Sum([]byte(tt.in))), "Hasher.Digest()")
+		})
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		in         string
+		want       string
+	}{
+		{
+			name:       "drop lines",
+			normalizer: DropLines(regexp.MustCompile(`^Generated at `), regexp.MustCompile(`tmp`)),
+			in:         "Generated at 2019-11-01\nfoo\n/tmp/bar\nbaz",
+			want:       "foo\nbaz",
+		}, {
+			name:       "replace",
+			normalizer: ReplaceRegexp(regexp.MustCompile(`/tmp/[^/]+`), "WORK_DIR"),
+			in:         "foo /tmp/abc/go.sum\n",
+			want:       "foo WORK_DIR/go.sum\n",
+		}, {
+			name: "chain",
+			normalizer: Normalizers(
+				ReplaceRegexp(regexp.MustCompile(`linux_\w+`), "PLATFORM"),
+				DropLines(regexp.MustCompile(`^#`)),
+			),
+			in:   "# linux_amd64\nfoo linux_386\n",
+			want: "foo PLATFORM\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(tt.normalizer([]byte(tt.in))), "Normalizer")
+		})
+	}
+}
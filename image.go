@@ -0,0 +1,371 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// imagePlatform is the platform in the config of the image.
+type imagePlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant"`
+}
+
+// imageManifest is the manifest of the image in the tarball.
+// Config and Layers are the names of the entries in the tarball.
+type imageManifest struct {
+	Config string
+	Layers []string
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+func ociBlob(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
+
+// tarEntry is the position of the content of the entry in the tarball.
+type tarEntry struct {
+	offset int64
+	size   int64
+}
+
+// tarIndex is the index of the entries in the tarball, so that the entries are read without rescanning.
+type tarIndex struct {
+	name    string
+	entries map[string]tarEntry
+}
+
+// indexTar reads the headers of the tarball in one pass.
+func indexTar(name string) (*tarIndex, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	x := &tarIndex{name: name, entries: map[string]tarEntry{}}
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return x, nil
+		}
+		if err != nil {
+			return nil, wrapf(err, "indexTar '%s'", name)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		// Next の直後は内容の先頭を指している.
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, wrapf(err, "indexTar '%s'", name)
+		}
+		x.entries[path.Clean(h.Name)] = tarEntry{offset: offset, size: h.Size}
+	}
+}
+
+// open calls fn with the reader of the entry in the tarball.
+func (x *tarIndex) open(entry string, fn func(r io.Reader) error) error {
+	entry = path.Clean(entry)
+	e, ok := x.entries[entry]
+	if ok == false {
+		return fmt.Errorf("%s is not found in '%s'", entry, x.name)
+	}
+	f, err := os.Open(x.name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn(io.NewSectionReader(f, e.offset, e.size))
+}
+
+func (x *tarIndex) readJSON(entry string, v interface{}) error {
+	return x.open(entry, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(v)
+	})
+}
+
+// imageManifests returns the manifests in the `docker save` tarball(manifest.json) or
+// the OCI layout tarball(index.json).
+func imageManifests(x *tarIndex) ([]imageManifest, error) {
+	docker := []struct {
+		Config string
+		Layers []string
+	}{}
+	if err := x.readJSON("manifest.json", &docker); err == nil {
+		ret := make([]imageManifest, len(docker))
+		for i, m := range docker {
+			ret[i] = imageManifest{Config: m.Config, Layers: m.Layers}
+		}
+		return ret, nil
+	}
+
+	ret := []imageManifest{}
+	var walk func(entry string) error
+	walk = func(entry string) error {
+		index := struct {
+			MediaType string          `json:"mediaType"`
+			Manifests []ociDescriptor `json:"manifests"`
+			Config    ociDescriptor   `json:"config"`
+			Layers    []ociDescriptor `json:"layers"`
+		}{}
+		if err := x.readJSON(entry, &index); err != nil {
+			return err
+		}
+		if len(index.Manifests) > 0 {
+			for _, m := range index.Manifests {
+				if err := walk(ociBlob(m.Digest)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if index.Config.Digest == "" {
+			// attestation 等の image 以外の manifest.
+			return nil
+		}
+		m := imageManifest{Config: ociBlob(index.Config.Digest)}
+		for _, l := range index.Layers {
+			m.Layers = append(m.Layers, ociBlob(l.Digest))
+		}
+		ret = append(ret, m)
+		return nil
+	}
+	if err := walk("index.json"); err != nil {
+		return nil, wrapf(err, "imageManifests '%s'", x.name)
+	}
+	return ret, nil
+}
+
+// walkLayer calls fn for each entry in the layer(tar or tar.gz) of the image.
+func walkLayer(x *tarIndex, layer string, fn func(h *tar.Header, r io.Reader) error) error {
+	return x.open(layer, func(r io.Reader) error {
+		br := bufio.NewReader(r)
+		var lr io.Reader = br
+		if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+			gr, err := gzip.NewReader(br)
+			if err != nil {
+				return err
+			}
+			defer gr.Close()
+			lr = gr
+		}
+		tr := tar.NewReader(lr)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return wrapf(err, "reading layer %s", layer)
+			}
+			if err := fn(h, tr); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// imageFile is the regular file in the merged filesystem of the image.
+type imageFile struct {
+	layer string
+	mode  os.FileMode
+}
+
+// removeTree removes p and the files under p.
+func removeTree(files map[string]imageFile, p string) {
+	for f := range files {
+		if f == p || strings.HasPrefix(f, p+"/") {
+			delete(files, f)
+		}
+	}
+}
+
+// mergeLayers returns the regular files in the image.
+// Whiteouts(.wh.<name>, .wh..wh..opq) remove the files in the lower layers.
+func mergeLayers(x *tarIndex, layers []string) (map[string]imageFile, error) {
+	files := map[string]imageFile{}
+	for _, l := range layers {
+		added := map[string]imageFile{}
+		err := walkLayer(x, l, func(h *tar.Header, r io.Reader) error {
+			p := path.Clean("/" + h.Name)
+			dir, base := path.Split(p)
+			dir = path.Clean(dir)
+			switch {
+			case base == ".wh..wh..opq":
+				removeTree(files, dir)
+				if dir == "/" {
+					files = map[string]imageFile{}
+				}
+			case strings.HasPrefix(base, ".wh."):
+				removeTree(files, path.Join(dir, strings.TrimPrefix(base, ".wh.")))
+			case h.Typeflag == tar.TypeReg:
+				added[p] = imageFile{layer: l, mode: h.FileInfo().Mode()}
+			default:
+				// ディレクトリ等で上書きされた場合.
+				removeTree(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for p, f := range added {
+			files[p] = f
+		}
+	}
+	return files, nil
+}
+
+// imageBinary is the Go executable in the image.
+type imageBinary struct {
+	path     string
+	layer    string
+	platform imagePlatform
+}
+
+// findBinariesInImage returns the Go executables in the image tarball.
+// It reads the tarball in memory(no files are written).
+func findBinariesInImage(x *tarIndex) ([]imageBinary, error) {
+	manifests, err := imageManifests(x)
+	if err != nil {
+		return nil, err
+	}
+	ret := []imageBinary{}
+	for _, m := range manifests {
+		p := imagePlatform{}
+		if err := x.readJSON(m.Config, &p); err != nil {
+			return nil, wrapf(err, "findBinariesInImage reading config")
+		}
+		files, err := mergeLayers(x, m.Layers)
+		if err != nil {
+			return nil, wrapf(err, "findBinariesInImage")
+		}
+		for _, l := range m.Layers {
+			err := walkLayer(x, l, func(h *tar.Header, r io.Reader) error {
+				fp := path.Clean("/" + h.Name)
+				f, ok := files[fp]
+				if ok == false || f.layer != l || f.mode&0111 == 0 || h.Typeflag != tar.TypeReg {
+					return nil
+				}
+				if _, _, ok := readGoExecutable(r); ok {
+					ret = append(ret, imageBinary{path: fp, layer: l, platform: p})
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, wrapf(err, "findBinariesInImage")
+			}
+		}
+	}
+	return ret, nil
+}
+
+// extractFromImage extracts the file in the layer of the image into dst.
+func extractFromImage(x *tarIndex, layer, entry, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return wrapf(err, "extractFromImage")
+	}
+	found := false
+	err := walkLayer(x, layer, func(h *tar.Header, r io.Reader) error {
+		if found || path.Clean("/"+h.Name) != entry {
+			return nil
+		}
+		found = true
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		_, err = io.Copy(out, r)
+		return err
+	})
+	if err != nil {
+		return wrapf(err, "extractFromImage '%s'", x.name)
+	}
+	if found == false {
+		return fmt.Errorf("extractFromImage '%s': %s is not found", x.name, entry)
+	}
+	return nil
+}
+
+// planImage returns PlanEntry of each Go executable in the image tarball.
+// The os/arch are taken from the config of the image.
+func (d *baseDist) planImage(name string) (Plan, error) {
+	// Run の extract でも同じインデックスを使う.
+	x, err := indexTar(name)
+	if err != nil {
+		return nil, err
+	}
+	d.imageIndex = x
+	binaries, err := findBinariesInImage(x)
+	if err != nil {
+		return nil, err
+	}
+	cnt := map[string]int{}
+	seen := map[string]string{}
+	for _, b := range binaries {
+		k := b.platform.OS + "_" + b.platform.Architecture + "_" + b.platform.Variant
+		cnt[k]++
+		// 同じ名前のバイナリは Dir と出力ファイルが重なるので扱えない.
+		if p, ok := seen[path.Base(b.path)+"_"+k]; ok {
+			return nil, fmt.Errorf("planImage '%s': %s and %s have the same name on %s", name, p, b.path, strings.TrimSuffix(k, "_"))
+		}
+		seen[path.Base(b.path)+"_"+k] = b.path
+	}
+	p := Plan{}
+	for _, b := range binaries {
+		arch := b.platform.Architecture
+		if b.platform.Variant != "" {
+			arch += "_" + b.platform.Variant
+		}
+		e := PlanEntry{
+			Dir:          path.Base(b.path) + "_" + b.platform.OS + "_" + arch,
+			Image:        name,
+			ImageLayer:   b.layer,
+			ArchiveEntry: b.path,
+			Os:           b.platform.OS,
+			Arch:         arch,
+			ReplacedOs:   ReplaceItem(d.replaceOs, b.platform.OS),
+			ReplacedArch: ReplaceItem(d.replaceArch, arch),
+		}
+		e.Binary = filepath.Join(d.workDir, "images", e.Dir, path.Base(b.path))
+		if cnt[b.platform.OS+"_"+b.platform.Architecture+"_"+b.platform.Variant] > 1 {
+			// 同じプラットフォームに複数のバイナリがある場合はバイナリ名を含める.
+			e.OutFile = filepath.Join(d.outDir, d.outputs[0].fileName(path.Base(b.path)+"_"+e.Platform()))
+		} else {
+			e.OutFile = filepath.Join(d.outDir, d.outputs[0].fileName(e.Platform()))
+		}
+		p = append(p, e)
+	}
+	return p, nil
+}
+
+// imageIndexOf returns the index of the image tarball that is indexed by planImage.
+func (d *baseDist) imageIndexOf(name string) (*tarIndex, error) {
+	if d.imageIndex != nil && d.imageIndex.name == name {
+		return d.imageIndex, nil
+	}
+	x, err := indexTar(name)
+	if err != nil {
+		return nil, err
+	}
+	d.imageIndex = x
+	return x, nil
+}
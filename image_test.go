@@ -0,0 +1,301 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTarFile struct {
+	name string
+	body []byte
+	mode int64
+	dir  bool
+}
+
+func testTar(t *testing.T, files []testTarFile, gz bool) []byte {
+	buf := &bytes.Buffer{}
+	var w io.Writer = buf
+	var gw *gzip.Writer
+	if gz {
+		gw = gzip.NewWriter(buf)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		h := &tar.Header{Name: f.name, Mode: f.mode, Size: int64(len(f.body)), Typeflag: tar.TypeReg}
+		if f.dir {
+			h.Typeflag = tar.TypeDir
+			h.Size = 0
+		}
+		err := tw.WriteHeader(h)
+		assert.Nil(t, err, "check")
+		_, err = tw.Write(f.body)
+		assert.Nil(t, err, "check")
+	}
+	assert.Nil(t, tw.Close(), "check")
+	if gw != nil {
+		assert.Nil(t, gw.Close(), "check")
+	}
+	return buf.Bytes()
+}
+
+func Test_mergeLayers(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	workDir := filepath.Join(cwd, "testdata", "work_image")
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	tests := []struct {
+		name   string
+		layers [][]testTarFile
+		want   []string
+	}{
+		{
+			name: "basic",
+			layers: [][]testTarFile{
+				{{name: "usr/bin/a", mode: 0755}, {name: "usr/bin/b", mode: 0755}},
+				{{name: "usr/bin/c", mode: 0755}},
+			},
+			want: []string{"/usr/bin/a", "/usr/bin/b", "/usr/bin/c"},
+		}, {
+			name: "whiteout",
+			layers: [][]testTarFile{
+				{{name: "usr/bin/a", mode: 0755}, {name: "opt/x/b", mode: 0755}},
+				{{name: "usr/bin/.wh.a"}, {name: "opt/.wh.x"}},
+			},
+			want: []string{},
+		}, {
+			name: "opaque",
+			layers: [][]testTarFile{
+				{{name: "usr/bin/a", mode: 0755}, {name: "usr/lib/b", mode: 0755}},
+				{{name: "usr/bin/.wh..wh..opq"}, {name: "usr/bin/c", mode: 0755}},
+			},
+			want: []string{"/usr/bin/c", "/usr/lib/b"},
+		}, {
+			name: "replaced by dir",
+			layers: [][]testTarFile{
+				{{name: "usr/bin/a", mode: 0755}},
+				{{name: "usr/bin/a", dir: true, mode: 0755}},
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := []testTarFile{}
+			layers := []string{}
+			for i, l := range tt.layers {
+				name := string(rune('a'+i)) + "/layer.tar"
+				image = append(image, testTarFile{name: name, body: testTar(t, l, i%2 == 1), mode: 0644})
+				layers = append(layers, name)
+			}
+			imageFile := filepath.Join(workDir, "image.tar")
+			err := ioutil.WriteFile(imageFile, testTar(t, image, false), 0644)
+			assert.Nil(t, err, "check")
+
+			x, err := indexTar(imageFile)
+			assert.Nil(t, err, "check")
+			files, err := mergeLayers(x, layers)
+			assert.Nil(t, err, "mergeLayers()")
+			got := []string{}
+			for p := range files {
+				got = append(got, p)
+			}
+			assert.ElementsMatch(t, tt.want, got, "mergeLayers()")
+		})
+	}
+}
+
+func Test_baseDist_Run_With_Image(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	imageDir := filepath.Join(testDir, "work_image")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	for _, d := range []string{workDir, outDir, imageDir} {
+		err = ResetDir(d, os.ModePerm)
+		assert.Nil(t, err, "check")
+		defer os.RemoveAll(d)
+	}
+
+	bin, err := ioutil.ReadFile(filepath.Join(testDir, "distDir", "linux_amd64", "my_cmd"))
+	assert.Nil(t, err, "check")
+
+	layer1 := testTar(t, []testTarFile{
+		{name: "usr/bin/my_cmd", body: bin, mode: 0755},
+		{name: "opt/old/my_tool", body: bin, mode: 0755},
+		{name: "etc/my_cmd.bin", body: bin, mode: 0644},
+		{name: "etc/README", body: []byte("readme"), mode: 0644},
+	}, false)
+	layer2 := testTar(t, []testTarFile{
+		{name: "opt/.wh.old"},
+		{name: "app/my_app", body: bin, mode: 0755},
+	}, true)
+
+	tests := []struct {
+		name     string
+		image    []testTarFile
+		wantPlan Plan
+		want     []string
+	}{
+		{
+			name: "docker save",
+			image: []testTarFile{
+				{name: "manifest.json", body: []byte(`[{"Config":"config.json","Layers":["l1/layer.tar","l2/layer.tar"]}]`), mode: 0644},
+				{name: "config.json", body: []byte(`{"os":"linux","architecture":"arm64","variant":"v8"}`), mode: 0644},
+				{name: "l1/layer.tar", body: layer1, mode: 0644},
+				{name: "l2/layer.tar", body: layer2, mode: 0644},
+			},
+			wantPlan: Plan{
+				{
+					Dir:          "my_cmd_linux_arm64_v8",
+					Binary:       filepath.Join(workDir, "images", "my_cmd_linux_arm64_v8", "my_cmd"),
+					Image:        filepath.Join(imageDir, "image.tar"),
+					ImageLayer:   "l1/layer.tar",
+					ArchiveEntry: "/usr/bin/my_cmd",
+					Os:           "linux",
+					Arch:         "arm64_v8",
+					ReplacedOs:   "linux",
+					ReplacedArch: "arm64_v8",
+					OutFile:      filepath.Join(outDir, "CREDITS_my_cmd_linux_arm64_v8"),
+				}, {
+					Dir:          "my_app_linux_arm64_v8",
+					Binary:       filepath.Join(workDir, "images", "my_app_linux_arm64_v8", "my_app"),
+					Image:        filepath.Join(imageDir, "image.tar"),
+					ImageLayer:   "l2/layer.tar",
+					ArchiveEntry: "/app/my_app",
+					Os:           "linux",
+					Arch:         "arm64_v8",
+					ReplacedOs:   "linux",
+					ReplacedArch: "arm64_v8",
+					OutFile:      filepath.Join(outDir, "CREDITS_my_app_linux_arm64_v8"),
+				},
+			},
+			want: []string{"CREDITS_my_cmd_linux_arm64_v8", "CREDITS_my_app_linux_arm64_v8"},
+		}, {
+			name: "oci layout",
+			image: []testTarFile{
+				{name: "oci-layout", body: []byte(`{"imageLayoutVersion":"1.0.0"}`), mode: 0644},
+				{name: "index.json", body: []byte(`{"manifests":[{"digest":"sha256:idx"}]}`), mode: 0644},
+				{name: "blobs/sha256/idx", body: []byte(`{"manifests":[{"digest":"sha256:m1"},{"digest":"sha256:att"}]}`), mode: 0644},
+				{name: "blobs/sha256/m1", body: []byte(`{"config":{"digest":"sha256:c1"},"layers":[{"digest":"sha256:l1"}]}`), mode: 0644},
+				{name: "blobs/sha256/att", body: []byte(`{"layers":[{"digest":"sha256:l1"}]}`), mode: 0644},
+				{name: "blobs/sha256/c1", body: []byte(`{"os":"linux","architecture":"amd64"}`), mode: 0644},
+				{name: "blobs/sha256/l1", body: testTar(t, []testTarFile{{name: "usr/bin/my_cmd", body: bin, mode: 0755}}, true), mode: 0644},
+			},
+			wantPlan: Plan{
+				{
+					Dir:          "my_cmd_linux_amd64",
+					Binary:       filepath.Join(workDir, "images", "my_cmd_linux_amd64", "my_cmd"),
+					Image:        filepath.Join(imageDir, "image.tar"),
+					ImageLayer:   "blobs/sha256/l1",
+					ArchiveEntry: "/usr/bin/my_cmd",
+					Os:           "linux",
+					Arch:         "amd64",
+					ReplacedOs:   "linux",
+					ReplacedArch: "amd64",
+					OutFile:      filepath.Join(outDir, "CREDITS_linux_amd64"),
+				},
+			},
+			want: []string{"CREDITS_linux_amd64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(outDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			err = ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			imageFile := filepath.Join(imageDir, "image.tar")
+			err = ioutil.WriteFile(imageFile, testTar(t, tt.image, false), 0644)
+			assert.Nil(t, err, "check")
+
+			d := NewDistBuilder().
+				Image(imageFile).
+				OutDir(outDir).
+				WorkDir(workDir).
+				Uniq(false).
+				OutputBuilder(
+					NewOutputBuilder().
+						GoSumFile(filepath.Join(goSumDir, "go.sum")).
+						runFunc(func(argv []string, outStream, errStream io.Writer) error {
+							_, err := io.Copy(outStream, strings.NewReader("test"))
+							return err
+						}),
+				).
+				Build()
+
+			p, err := d.(Planner).Plan()
+			assert.Nil(t, err, "baseDist.Plan()")
+			assert.Equal(t, tt.wantPlan, p, "baseDist.Plan()")
+			_, err = os.Stat(filepath.Join(workDir, "images"))
+			assert.True(t, os.IsNotExist(err), "Plan does not extract")
+
+			err = d.Run()
+			assert.Nil(t, err, "baseDist.Run()")
+			files, err := ioutil.ReadDir(outDir)
+			assert.Nil(t, err, "check")
+			gotFileNames := make([]string, len(files))
+			for i, f := range files {
+				gotFileNames[i] = f.Name()
+			}
+			assert.ElementsMatch(t, tt.want, gotFileNames, "files")
+			for _, e := range p {
+				b, err := ioutil.ReadFile(e.Binary)
+				assert.Nil(t, err, "extracted")
+				assert.Equal(t, bin, b, "extracted")
+			}
+		})
+	}
+}
+
+func Test_baseDist_planImage_SameName(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	workDir := filepath.Join(testDir, "work_dist")
+	imageDir := filepath.Join(testDir, "work_image")
+
+	err = ResetDir(imageDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(imageDir)
+
+	bin, err := ioutil.ReadFile(filepath.Join(testDir, "distDir", "linux_amd64", "my_cmd"))
+	assert.Nil(t, err, "check")
+
+	// 同じプラットフォームに同じ名前のバイナリがある.
+	imageFile := filepath.Join(imageDir, "image.tar")
+	err = ioutil.WriteFile(imageFile, testTar(t, []testTarFile{
+		{name: "manifest.json", body: []byte(`[{"Config":"config.json","Layers":["l1/layer.tar"]}]`), mode: 0644},
+		{name: "config.json", body: []byte(`{"os":"linux","architecture":"amd64"}`), mode: 0644},
+		{name: "l1/layer.tar", body: testTar(t, []testTarFile{
+			{name: "usr/bin/my_cmd", body: bin, mode: 0755},
+			{name: "opt/bin/my_cmd", body: bin, mode: 0755},
+		}, false), mode: 0644},
+	}, false), 0644)
+	assert.Nil(t, err, "check")
+
+	d := NewDistBuilder().
+		Image(imageFile).
+		WorkDir(workDir).
+		Build().(*baseDist)
+	_, err = d.planImage(imageFile)
+	assert.ErrorContains(t, err, "/usr/bin/my_cmd and /opt/bin/my_cmd have the same name on linux_amd64", "baseDist.planImage()")
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NoticePrefix is the prefix of the name of the NOTICE entry in the output.
+// The entry is rendered in the same format as the license(ie. "NOTICE: github.com/foo/bar").
+const NoticePrefix = "NOTICE: "
+
+var noticeFiles = []string{"NOTICE", "NOTICE.txt", "NOTICE.md"}
+
+// escapeModulePath escapes the module path for the module cache(ie. github.com/!songmu/gocredits).
+func escapeModulePath(p string) string {
+	b := &strings.Builder{}
+	for _, r := range p {
+		if unicode.IsUpper(r) {
+			b.WriteRune('!')
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// goEnv returns the value of `go env key` that is run by the runner.
+// The value is cached until the next resolve(once per Flush).
+func (c *baseOutput) goEnv(key string) (string, error) {
+	if v, ok := c.goEnvs[key]; ok {
+		return v, nil
+	}
+	out := &bytes.Buffer{}
+	errStream := &strings.Builder{}
+	if err := c.runner.Run(context.Background(), "go", []string{"env", key}, nil, nil, out, errStream); err != nil {
+		return "", newGeneratorError("go", []string{"env", key}, errStream.String(), err)
+	}
+	if c.goEnvs == nil {
+		c.goEnvs = map[string]string{}
+	}
+	c.goEnvs[key] = strings.TrimSpace(out.String())
+	return c.goEnvs[key], nil
+}
+
+// modCacheDir returns the module cache directory(GOMODCACHE).
+func (c *baseOutput) modCacheDir() (string, error) {
+	if d := os.Getenv("GOMODCACHE"); d != "" {
+		return d, nil
+	}
+	return c.goEnv("GOMODCACHE")
+}
+
+// moduleDir returns the directory of the module(in the vendor directory or the module cache).
+func (c *baseOutput) moduleDir(m Module) (string, error) {
+	if c.vendorDir != "" {
+		return filepath.Join(c.vendorDir, filepath.FromSlash(m.Path)), nil
+	}
+	d, err := c.modCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, filepath.FromSlash(escapeModulePath(m.Path))+"@"+m.Version), nil
+}
+
+// moduleFS returns the file system that moduleDir is read from.
+// The vendor directory is in FS, the module cache is always read from the OS(as the generators do).
+func (c *baseOutput) moduleFS() fs.FS {
+	if c.vendorDir != "" {
+		return c.fs
+	}
+	return OSFS()
+}
+
+// writeNotices writes NOTICE files of the modules(generated and overridden).
+// Modules that have no NOTICE file are skipped.
+func (c *baseOutput) writeNotices(w io.Writer) error {
+	if c.notices == false {
+		return nil
+	}
+	mods := append([]Module{}, c.generated...)
+	for _, a := range c.applied {
+		mods = append(mods, a.module)
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+	for _, m := range mods {
+		dir, err := c.moduleDir(m)
+		if err != nil {
+			return wrapf(err, "writeNotices")
+		}
+		for _, n := range noticeFiles {
+			b, err := fs.ReadFile(c.moduleFS(), filepath.Join(dir, n))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return wrapf(err, "writeNotices")
+			}
+			if err := writeLicense(w, NoticePrefix+m.Path, "https://"+m.Path, string(b)); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
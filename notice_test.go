@@ -0,0 +1,105 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hankei6km/go-ac/credits"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_escapeModulePath(t *testing.T) {
+	assert.Equal(t, "github.com/!songmu/gocredits", escapeModulePath("github.com/Songmu/gocredits"), "escapeModulePath()")
+	assert.Equal(t, "gopkg.in/yaml.v2", escapeModulePath("gopkg.in/yaml.v2"), "escapeModulePath()")
+}
+
+func Test_baseOutput_Flush_With_Notices(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	workDir := filepath.Join(testDir, "work_notice")
+	t.Setenv("GOMODCACHE", filepath.Join(testDir, "modCache"))
+	runFunc := func(argv []string, outStream, errStream io.Writer) error {
+		b := &strings.Builder{}
+		writeLicense(b, "gopkg.in/yaml.v2", "https://gopkg.in/yaml.v2", "yaml license")
+		_, err := io.Copy(outStream, strings.NewReader(b.String()))
+		return err
+	}
+	tests := []struct {
+		name    string
+		builder OutputBuilder
+		want    []credits.Entry
+	}{
+		{
+			name:    "module cache",
+			builder: NewOutputBuilder().Notices(true),
+			want: []credits.Entry{
+				{Name: "gopkg.in/yaml.v2", URL: "https://gopkg.in/yaml.v2", Content: "yaml license", Notice: "yaml notice from cache\n"},
+			},
+		}, {
+			name:    "vendor",
+			builder: NewOutputBuilder().Notices(true).VendorDir(filepath.Join(testDir, "vendorDir", "ok")),
+			want: []credits.Entry{
+				{Name: "gopkg.in/yaml.v2", URL: "https://gopkg.in/yaml.v2", Content: "yaml license\n", Notice: "yaml notice\n"},
+			},
+		}, {
+			name:    "disabled",
+			builder: NewOutputBuilder(),
+			want: []credits.Entry{
+				{Name: "gopkg.in/yaml.v2", URL: "https://gopkg.in/yaml.v2", Content: "yaml license"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			got := &strings.Builder{}
+			_, err = tt.builder.
+				WorkDir(workDir).
+				Binary(filepath.Join(testDir, "binDir", "my_cmd")).
+				GoSumFile(filepath.Join(testDir, "goSum", "go.sum")).
+				OutStream(got).
+				ErrStream(ioutil.Discard).
+				runFunc(runFunc).
+				Build().
+				Flush()
+			assert.Nil(t, err, "Flush()")
+			e := []credits.Entry{}
+			for _, c := range credits.Parse(got.String()) {
+				// Go の LICENSE(vendor)は除く.
+				if strings.HasPrefix(c.Name, "Go ") == false {
+					e = append(e, c)
+				}
+			}
+			assert.Equal(t, tt.want, e, "Flush()")
+		})
+	}
+}
+
+func Test_baseOutput_moduleDir_Cache(t *testing.T) {
+	t.Setenv("GOMODCACHE", "")
+	r := &RecordingRunner{Runner: RunnerFunc(func(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		_, err := io.WriteString(stdout, "/modcache\n")
+		return err
+	})}
+	c := NewOutputBuilder().Runner(r).runFunc(nil).Build().(*baseOutput)
+	for _, m := range []Module{{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"}, {Path: "github.com/Foo/bar", Version: "v1.0.0"}} {
+		_, err := c.moduleDir(m)
+		assert.Nil(t, err, "baseOutput.moduleDir()")
+	}
+	got, err := c.moduleDir(Module{Path: "github.com/Foo/bar", Version: "v1.0.0"})
+	assert.Nil(t, err, "baseOutput.moduleDir()")
+	assert.Equal(t, filepath.Join("/modcache", "github.com", "!foo", "bar@v1.0.0"), got, "baseOutput.moduleDir()")
+	assert.Equal(t, []Command{{Name: "go", Args: []string{"env", "GOMODCACHE"}}}, r.Commands(), "go env is run once")
+}
@@ -5,10 +5,13 @@ package ac
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -22,18 +25,56 @@ type Output interface {
 	Flush() (hash []byte, err error)
 }
 
+// ExclusionReporter is implemented by Output that reports the modules excluded by Flush.
+// The outputs built by OutputBuilder implement it.
+//
+//	if r, ok := o.(ac.ExclusionReporter); ok {
+//		fmt.Println(r.Excluded())
+//	}
+type ExclusionReporter interface {
+	// Excluded returns the modules that are excluded by Flush.
+	Excluded() []Exclusion
+}
+
+// Digester is implemented by Output that reports the digest of the flushed content.
+// The outputs built by OutputBuilder implement it.
+type Digester interface {
+	// Digest returns the hash returned by Flush in the form of `<name>:<hex>`(ie. sha256:e3b0c442...).
+	Digest() string
+}
+
+// PackageReporter is implemented by Output that reports the packages linked into the binary.
+// The outputs built by OutputBuilder implement it.
+type PackageReporter interface {
+	// Packages returns the packages of each module that are linked into the binary.
+	// It is available only if OutputBuilder.Packages(true) is set.
+	Packages() []ModulePackages
+}
+
 // OutputBuilder builds CreaditsFile.
 //
 // 今回はそれほどコスト気にする必要はないので、各メソッドで深いコピー(ぽいこと)を行う.
 type OutputBuilder interface {
 	GoSumFile(string) OutputBuilder
+	GoSumFiles([]string) OutputBuilder
+	GoWork(string) OutputBuilder
 	WorkDir(string) OutputBuilder
 	Binary(string) OutputBuilder
 	OutStream(io.Writer) OutputBuilder
 	ErrStream(io.Writer) OutputBuilder
+	Overrides([]Override) OutputBuilder
+	Exclude([]string) OutputBuilder
+	Cache(*Cache) OutputBuilder
+	VendorDir(string) OutputBuilder
+	Packages(bool) OutputBuilder
+	Notices(bool) OutputBuilder
+	Hash(Hasher) OutputBuilder
+	FS(WritableFS) OutputBuilder
+	Runner(Runner) OutputBuilder
 
 	ProgOutput
 	FuncOutputBuilder
+	SourceOutputBuilder
 
 	Branch() OutputBuilder
 	Build() Output
@@ -41,15 +82,29 @@ type OutputBuilder interface {
 
 type baseOutputBuilder struct {
 	goSumFile   string
+	goSumFiles  []string
+	goWork      string
 	workDir     string
 	binary      string
 	prog        string
 	runFuncIntl runFuncType
 	outStream   io.Writer
 	errStream   io.Writer
+	overrides   []Override
+	exclude     []string
+	cache       *Cache
+	vendorDir   string
+	packages    bool
+	notices     bool
+	hasher      Hasher
+	fs          WritableFS
+	runner      Runner
 
 	modulesCmd  string
 	modulesArgs []string
+
+	source       Source
+	listFuncIntl listFuncType
 }
 
 func (b *baseOutputBuilder) GoSumFile(goSumFile string) OutputBuilder {
@@ -58,6 +113,22 @@ func (b *baseOutputBuilder) GoSumFile(goSumFile string) OutputBuilder {
 	return bb
 }
 
+// GoSumFiles sets go.sum files that are used instead of GoSumFile.
+// They are merged(and deduplicated) by prune.
+func (b *baseOutputBuilder) GoSumFiles(goSumFiles []string) OutputBuilder {
+	bb := b.branch()
+	bb.goSumFiles = goSumFiles
+	return bb
+}
+
+// GoWork sets go.work. The go.sum files of the modules in the workspace and go.work.sum are used
+// instead of GoSumFile(GoSumFiles).
+func (b *baseOutputBuilder) GoWork(goWork string) OutputBuilder {
+	bb := b.branch()
+	bb.goWork = goWork
+	return bb
+}
+
 func (b *baseOutputBuilder) WorkDir(workDir string) OutputBuilder {
 	bb := b.branch()
 	bb.workDir = workDir
@@ -82,6 +153,72 @@ func (b *baseOutputBuilder) ErrStream(errStream io.Writer) OutputBuilder {
 	return bb
 }
 
+func (b *baseOutputBuilder) Overrides(overrides []Override) OutputBuilder {
+	bb := b.branch()
+	bb.overrides = overrides
+	return bb
+}
+
+func (b *baseOutputBuilder) Exclude(patterns []string) OutputBuilder {
+	bb := b.branch()
+	bb.exclude = patterns
+	return bb
+}
+
+func (b *baseOutputBuilder) Cache(cache *Cache) OutputBuilder {
+	bb := b.branch()
+	bb.cache = cache
+	return bb
+}
+
+// VendorDir sets the vendor directory(built with -mod=vendor).
+// The modules are checked against vendor/modules.txt, and the licenses are read from the vendor directory
+// instead of the generator.
+// It can not be used with Prog(Flush returns ErrProgWithVendor).
+func (b *baseOutputBuilder) VendorDir(vendorDir string) OutputBuilder {
+	bb := b.branch()
+	bb.vendorDir = vendorDir
+	return bb
+}
+
+// Packages enables the analysis of the packages that are linked into the binary(see PackageReporter).
+func (b *baseOutputBuilder) Packages(packages bool) OutputBuilder {
+	bb := b.branch()
+	bb.packages = packages
+	return bb
+}
+
+// Notices enables collecting NOTICE files(NOTICE, NOTICE.txt, NOTICE.md) of the modules.
+// They are rendered after the licenses(see NoticePrefix).
+func (b *baseOutputBuilder) Notices(notices bool) OutputBuilder {
+	bb := b.branch()
+	bb.notices = notices
+	return bb
+}
+
+// Hash sets the hash algorithm of Flush(SHA256 by default).
+func (b *baseOutputBuilder) Hash(hasher Hasher) OutputBuilder {
+	bb := b.branch()
+	bb.hasher = hasher
+	return bb
+}
+
+// FS sets the file system that go.sum(go.work) is read from and the pruned go.sum is written into(OSFS by default).
+// The generators(gocredits and Prog) read the files of the OS, so the pruned go.sum is copied into
+// the temporary directory of the OS for them. The module cache(GOMODCACHE) is read from the OS.
+func (b *baseOutputBuilder) FS(fsys WritableFS) OutputBuilder {
+	bb := b.branch()
+	bb.fs = fsys
+	return bb
+}
+
+// Runner sets Runner that runs `go version -m`, `go list`, `go env` and Prog(ExecRunner by default).
+func (b *baseOutputBuilder) Runner(runner Runner) OutputBuilder {
+	bb := b.branch()
+	bb.runner = runner
+	return bb
+}
+
 func (b *baseOutputBuilder) Prog(prog string) OutputBuilder {
 	bb := b.branch()
 	bb.prog = prog
@@ -113,96 +250,177 @@ func (b *baseOutputBuilder) Build() Output {
 }
 
 type baseOutput struct {
-	goSumFile string
-	workDir   string
-	binary    string
-	outStream io.Writer
-	errStream io.Writer
+	goSumFile  string
+	goSumFiles []string
+	goWork     string
+	workDir    string
+	binary     string
+	outStream  io.Writer
+	errStream  io.Writer
+	overrides  []Override
+	exclude    []string
+	cache      *Cache
+	vendorDir  string
+	packages   bool
+	notices    bool
+	hasher     Hasher
+	fs         WritableFS
+	runner     Runner
 
 	modulesCmd  string
 	modulesArgs []string
 
+	source   Source
+	listFunc listFuncType
+
 	builder OutputBuilder // 今回はおそらくつかわない.
+
+	mods      []Module
+	excluded  []Exclusion
+	goEnvs    map[string]string // the cache of goEnv.
+	applied   []appliedOverride
+	generated []Module // generator に渡されるモジュール.
+
+	listedPackages []string // Source の場合に go list で得られたパッケージ.
+	modPackages    []ModulePackages
+	digest         string
+	generatorID    string
+}
+
+// Module is a dependent module that is embedded in the binary.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
 }
 
 func (c *baseOutput) modules() ([]string, error) {
+	mods, err := c.readModules()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(mods))
+	for i, m := range mods {
+		paths[i] = m.Path
+	}
+	return paths, nil
+}
+
+func (c *baseOutput) readModules() ([]Module, error) {
 	r, w := io.Pipe()
 	go func() {
 		var err error
 		errStream := &strings.Builder{}
-		args := append(c.modulesArgs, c.binary)
+		args := append(append([]string{}, c.modulesArgs...), c.binary)
 		defer func() {
 			errText := errStream.String()
 			switch {
 			case err != nil:
-				w.CloseWithError(wrapf(err, "execute args(%s, %x)", c.modulesCmd, args))
+				w.CloseWithError(newGeneratorError(c.modulesCmd, args, errText, err))
 			case errText != "":
-				w.CloseWithError(wrapf(fmt.Errorf("%s", errText), "execute: args(%s, %x)", c.modulesCmd, args))
+				w.CloseWithError(newGeneratorError(c.modulesCmd, args, errText, errUnexpectedStderr))
 				// io.Copy(c.errStream, strings.NewReader(errText))
 			}
 			w.Close()
 		}()
-		cmd := exec.Command(c.modulesCmd, args...)
-		cmd.Stdout = w
-		cmd.Stderr = errStream
-		err = cmd.Start()
-		if err != nil {
-			return
-		}
-		err = cmd.Wait()
+		err = c.runner.Run(context.Background(), c.modulesCmd, args, nil, nil, w, errStream)
 	}()
-	mods := []string{}
+	mods := []Module{}
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		l := scanner.Text()
 		if strings.HasPrefix(l, "\t") {
 			t := strings.SplitN(l, "\t", 4)
 			if t[1] == "dep" {
-				mods = append(mods, t[2])
+				m := Module{Path: t[2]}
+				if len(t) > 3 {
+					m.Version = strings.SplitN(t[3], "\t", 2)[0]
+				}
+				mods = append(mods, m)
 			}
 		}
 	}
 	err := scanner.Err()
 	switch {
 	case err != nil:
+		if s, serr := fs.Stat(c.fs, c.binary); serr != nil {
+			return nil, wrapf(serr, "modules()")
+		} else if s.Mode().IsRegular() && isNotGoBinaryError(err) {
+			return nil, fmt.Errorf("modules() '%s': %w: %w", c.binary, ErrNotGoBinary, err)
+		}
 		return nil, wrapf(err, "modules()")
 	case len(mods) == 0:
-		return nil, wrapf(fmt.Errorf("depenet module not found in '%s'", c.binary), "modules()")
+		return nil, fmt.Errorf("modules() '%s': %w", c.binary, ErrNoModules)
 	}
 	return mods, nil
 }
 
+// sumFiles returns go.sum files that are merged by prune.
+func (c *baseOutput) sumFiles() ([]string, error) {
+	switch {
+	case c.goWork != "":
+		return goWorkSumFiles(c.fs, c.goWork)
+	case len(c.goSumFiles) > 0:
+		return c.goSumFiles, nil
+	}
+	return []string{c.goSumFile}, nil
+}
+
+// sumSource returns the name of go.sum source that is reported by PruneError.
+func (c *baseOutput) sumSource() string {
+	switch {
+	case c.goWork != "":
+		return c.goWork
+	case len(c.goSumFiles) > 0:
+		return strings.Join(c.goSumFiles, string(os.PathListSeparator))
+	}
+	return c.goSumFile
+}
+
 func (c *baseOutput) prune(modules []string) io.Reader {
 	r, w := io.Pipe()
 
 	go func() {
 		var errClose error
+		var errFile string
 		defer func() {
 			if errClose != nil {
-				w.CloseWithError(wrapf(errClose, "prune go.sum"))
+				w.CloseWithError(&PruneError{GoSumFile: errFile, Err: errClose})
 				return
 			}
 			w.Close()
 		}()
 
-		in, err := os.Open(c.goSumFile)
+		files, err := c.sumFiles()
 		if err != nil {
-			errClose = err
+			errClose, errFile = err, c.goWork
 			return
 		}
-		defer in.Close()
-
-		scanner := bufio.NewScanner(in)
-		for scanner.Scan() {
-			l := scanner.Text()
-			t := strings.SplitN(l, " ", 2)[0]
-			for _, m := range modules {
-				if m == t {
-					fmt.Fprintln(w, l)
+		// 複数の go.sum に同じ行がある場合は最初の行のみ.
+		seen := map[string]bool{}
+		for _, f := range files {
+			errFile = f
+			in, err := c.fs.Open(f)
+			if err != nil {
+				errClose = err
+				return
+			}
+			scanner := bufio.NewScanner(in)
+			for scanner.Scan() {
+				l := scanner.Text()
+				t := strings.SplitN(l, " ", 2)[0]
+				for _, m := range modules {
+					if m == t && seen[l] == false {
+						seen[l] = true
+						fmt.Fprintln(w, l)
+					}
 				}
 			}
+			errClose = scanner.Err()
+			in.Close()
+			if errClose != nil {
+				return
+			}
 		}
-		errClose = scanner.Err()
 	}()
 
 	return r
@@ -210,33 +428,183 @@ func (c *baseOutput) prune(modules []string) io.Reader {
 
 func (c *baseOutput) writePruned(modules []string) (outFile string, err error) {
 	outFile = filepath.Join(c.workDir, "go.sum")
-	out, err := os.Create(outFile)
+	b, err := io.ReadAll(c.prune(modules))
+	if err != nil {
+		var p *PruneError
+		if errors.As(err, &p) {
+			return "", err
+		}
+		return "", &PruneError{GoSumFile: c.sumSource(), Err: wrapf(err, "reading pruned lines")}
+	}
+	if err := c.fs.WriteFile(outFile, b, 0644); err != nil {
+		return "", &PruneError{GoSumFile: c.sumSource(), Err: wrapf(err, "writing pruned file")}
+	}
+	return outFile, nil
+}
+
+// stagedOutput is Output that can be run stage by stage.
+// Dist resolves the modules of each binary first, and renders once per distinct module set.
+type stagedOutput interface {
+	Output
+	ExclusionReporter
+	Digester
+	PackageReporter
+	resolve() error
+	resolved() []Module
+	render() (hash []byte, err error)
+	moduleSetKey() string
+	// generator returns the identity of the generator that is used by render.
+	generator() string
+}
+
+// resolve resolves the modules in the binary, and splits them into
+// the excluded modules, the overridden modules and the modules passed to the generator.
+func (c *baseOutput) resolve() error {
+	c.goEnvs = nil
+	mods, err := c.loadModules()
 	if err != nil {
-		return "", wrapf(err, "creating pruned file")
+		return stageErr(StageModules, err)
 	}
-	defer out.Close()
-	_, err = io.Copy(out, c.prune(modules))
+	c.mods = mods
+	// go.work 内のモジュール(first-party)は対象外.
+	mods, ws, err := c.excludeWorkspace(mods)
 	if err != nil {
-		return "", wrapf(err, "writing pruned file")
+		return stageErr(StageModules, err)
 	}
-	return outFile, nil
+	mods, c.excluded = excludeModules(c.exclude, mods)
+	c.excluded = append(ws, c.excluded...)
+	mods, c.applied = applyOverrides(c.overrides, mods)
+	for _, o := range unusedOverrides(c.overrides, c.applied) {
+		fmt.Fprintf(c.errStream, "override %s is not used in '%s'\n", o, c.target())
+	}
+	c.generated = mods
+	if err := c.checkVendor(); err != nil {
+		return stageErr(StageModules, err)
+	}
+	if c.packages {
+		p, err := c.readModulePackages()
+		if err != nil {
+			return stageErr(StageModules, err)
+		}
+		c.modPackages = p
+	}
+	return nil
+}
+
+// target returns the name of what the modules are read from(the binary or the source).
+func (c *baseOutput) target() string {
+	if c.source.enabled() {
+		return c.source.String()
+	}
+	return c.binary
+}
+
+func (c *baseOutput) resolved() []Module {
+	return c.mods
+}
+
+// moduleSetKey returns the key of the resolved modules.
+// The outputs that have the same key write the same content.
+func (c *baseOutput) moduleSetKey() string {
+	return CacheKey(c.mods, nil, "")
+}
+
+// prepare writes go.sum pruned by the generated modules into workDir.
+// Modules that are matched by overrides are not written to go.sum,
+// they are rendered by writeOverrides.
+func (c *baseOutput) prepare() error {
+	paths := make([]string, len(c.generated))
+	for i, m := range c.generated {
+		paths[i] = m.Path
+	}
+	if _, err := c.writePruned(paths); err != nil {
+		return stageErr(StagePrune, err)
+	}
+	return nil
+}
+
+// genDir returns the directory that the generators read the pruned go.sum from.
+// The go.sum in the file system other than OSFS is copied into the temporary directory of the OS,
+// cleanup removes it.
+func (c *baseOutput) genDir() (dir string, cleanup func(), err error) {
+	if isOSFS(c.fs) {
+		return c.workDir, func() {}, nil
+	}
+	b, err := fs.ReadFile(c.fs, filepath.Join(c.workDir, "go.sum"))
+	if err != nil {
+		return "", nil, wrapf(err, "reading pruned file")
+	}
+	dir, err = ioutil.TempDir("", "go-ac")
+	if err != nil {
+		return "", nil, wrapf(err, "creating temporary directory")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), b, 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, wrapf(err, "copying pruned file")
+	}
+	return dir, func() { os.RemoveAll(dir) }, nil
+}
+
+func (c *baseOutput) render() (hash []byte, err error) {
+	return
 }
 
 func (c *baseOutput) Flush() (hash []byte, err error) {
 	return
 }
 
+// sum returns the hash of the output, and keeps its digest.
+func (c *baseOutput) sum(b []byte) []byte {
+	h := c.hasher.Sum(b)
+	c.digest = c.hasher.Digest(h)
+	return h
+}
+
+func (c *baseOutput) generator() string {
+	return c.generatorID
+}
+
+func (c *baseOutput) Digest() string {
+	return c.digest
+}
+
+func (c *baseOutput) Excluded() []Exclusion {
+	return c.excluded
+}
+
+func (c *baseOutput) Packages() []ModulePackages {
+	return c.modPackages
+}
+
 func newBaseOutput(b *baseOutputBuilder) *baseOutput {
+	listFunc := b.listFuncIntl
+	if listFunc == nil {
+		listFunc = runnerListFunc(b.runner)
+	}
 	return &baseOutput{
-		goSumFile: b.goSumFile,
-		workDir:   b.workDir,
-		binary:    b.binary,
-		outStream: b.outStream,
-		errStream: b.errStream,
+		goSumFile:  b.goSumFile,
+		goSumFiles: b.goSumFiles,
+		goWork:     b.goWork,
+		workDir:    b.workDir,
+		binary:     b.binary,
+		outStream:  b.outStream,
+		errStream:  b.errStream,
+		overrides:  b.overrides,
+		exclude:    b.exclude,
+		cache:      b.cache,
+		vendorDir:  b.vendorDir,
+		packages:   b.packages,
+		notices:    b.notices,
+		hasher:     b.hasher,
+		fs:         b.fs,
+		runner:     b.runner,
 
 		modulesCmd:  b.modulesCmd,
 		modulesArgs: b.modulesArgs,
 
+		source:   b.source,
+		listFunc: listFunc,
+
 		builder: b.branch(),
 	}
 }
@@ -248,6 +616,8 @@ func NewOutputBuilder() OutputBuilder {
 		runFuncIntl: gocredits.Run,
 		outStream:   os.Stdout,
 		errStream:   os.Stderr,
+		fs:          OSFS(),
+		runner:      ExecRunner(),
 
 		modulesCmd:  "go",
 		modulesArgs: []string{"version", "-m"},
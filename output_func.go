@@ -4,8 +4,9 @@
 package ac
 
 import (
-	"crypto/sha256"
+	"bytes"
 	"io"
+	"strings"
 )
 
 // runFuncType defines type of function that is used in funcOutput.
@@ -23,20 +24,43 @@ type funcOutput struct {
 }
 
 func (c *funcOutput) Flush() (hash []byte, err error) {
-	modules, err := c.modules()
-	if err != nil {
-		return nil, wrapf(err, "erorr in ProgOutput.Flush")
+	if err := c.resolve(); err != nil {
+		return nil, wrapf(err, "error in funcOutput.Flush")
 	}
-	_, err = c.writePruned(modules)
-	if err != nil {
-		return nil, wrapf(err, "erorr in ProgOutput.Flush")
+	return c.render()
+}
+
+func (c *funcOutput) render() (hash []byte, err error) {
+	if err := c.prepare(); err != nil {
+		return nil, wrapf(err, "error in funcOutput.Flush")
+	}
+	buf := &bytes.Buffer{}
+	w := io.MultiWriter(c.outStream, buf)
+	if c.vendorDir != "" {
+		if err := c.generate(w, vendorID(c.vendorDir), c.writeVendorCredits); err != nil {
+			return nil, wrapf(stageErr(StageGenerate, err), "error in funcOutput.Flush - vendor")
+		}
+	} else {
+		dir, cleanup, err := c.genDir()
+		if err != nil {
+			return nil, wrapf(stageErr(StageGenerate, err), "error in funcOutput.Flush")
+		}
+		defer cleanup()
+		args := []string{dir}
+		errStream := &strings.Builder{}
+		if err := c.generate(w, gocreditsID(), func(w io.Writer) error {
+			return c.runFunc(args, w, io.MultiWriter(c.errStream, errStream))
+		}); err != nil {
+			return nil, wrapf(stageErr(StageGenerate, newGeneratorError("gocredits", args, errStream.String(), err)), "error in funcOutput.Flush")
+		}
+	}
+	if err := writeOverrides(w, c.fs, c.applied); err != nil {
+		return nil, wrapf(stageErr(StageGenerate, err), "error in funcOutput.Flush - overrides")
 	}
-	h := sha256.New()
-	w := io.MultiWriter(c.outStream, h)
-	if err := c.runFunc([]string{c.workDir}, w, c.errStream); err != nil {
-		return nil, wrapf(err, "erorr in ProgOutput.Flush - runFunc args(%s)", c.workDir)
+	if err := c.writeNotices(w); err != nil {
+		return nil, wrapf(stageErr(StageGenerate, err), "error in funcOutput.Flush - notices")
 	}
-	return h.Sum(nil), nil
+	return c.sum(buf.Bytes()), nil
 }
 
 func newEmbedOutput(b *baseOutputBuilder) *funcOutput {
@@ -58,7 +58,8 @@ func Test_embedOutput_Flush(t *testing.T) {
 			defer os.RemoveAll(workDir)
 
 			got := &strings.Builder{}
-			gotHash, err := tt.builder.OutStream(got).Build().Flush()
+			o := tt.builder.OutStream(got).Build()
+			gotHash, err := o.Flush()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("funcOutput.Flush() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -69,6 +70,7 @@ func Test_embedOutput_Flush(t *testing.T) {
 					fmt.Sprintf("%x", (gotHash)),
 					"funcOutput.Flush()",
 				)
+				assert.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(tt.want))), o.(Digester).Digest(), "funcOutput.Digest()")
 				assert.Equal(t, tt.want, got.String(), "funcOutput.Flush() outStream")
 			}
 		})
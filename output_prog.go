@@ -4,9 +4,10 @@
 package ac
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"context"
 	"io"
-	"os/exec"
+	"strings"
 )
 
 // ProgOutput adds properties to Output(Builder).
@@ -21,26 +22,43 @@ type progOutput struct {
 }
 
 func (c *progOutput) Flush() (hash []byte, err error) {
-	modules, err := c.modules()
-	if err != nil {
-		return nil, wrapf(err, "erorr in ProgOutput.Flush")
+	if c.vendorDir != "" {
+		return nil, wrapf(ErrProgWithVendor, "error in progOutput.Flush")
+	}
+	if err := c.resolve(); err != nil {
+		return nil, wrapf(err, "error in progOutput.Flush")
 	}
-	_, err = c.writePruned(modules)
+	return c.render()
+}
+
+func (c *progOutput) render() (hash []byte, err error) {
+	if c.vendorDir != "" {
+		return nil, wrapf(ErrProgWithVendor, "error in progOutput.Flush")
+	}
+	if err := c.prepare(); err != nil {
+		return nil, wrapf(err, "error in progOutput.Flush")
+	}
+	buf := &bytes.Buffer{}
+	w := io.MultiWriter(c.outStream, buf)
+	dir, cleanup, err := c.genDir()
 	if err != nil {
-		return nil, wrapf(err, "erorr in ProgOutput.Flush")
+		return nil, wrapf(stageErr(StageGenerate, err), "error in progOutput.Flush")
+	}
+	defer cleanup()
+	args := []string{dir}
+	errStream := &strings.Builder{}
+	if err := c.generate(w, progID(c.prog), func(w io.Writer) error {
+		return c.runner.Run(context.Background(), c.prog, args, nil, nil, w, io.MultiWriter(c.errStream, errStream))
+	}); err != nil {
+		return nil, wrapf(stageErr(StageGenerate, newGeneratorError(c.prog, args, errStream.String(), err)), "error in progOutput.Flush")
 	}
-	h := sha256.New()
-	w := io.MultiWriter(c.outStream, h)
-	cmd := exec.Command(c.prog, c.workDir)
-	cmd.Stdout = w
-	cmd.Stderr = c.errStream
-	if err := cmd.Start(); err != nil {
-		return nil, wrapf(err, "erorr in ProgOutput.Flush - start args(%s)", c.workDir)
+	if err := writeOverrides(w, c.fs, c.applied); err != nil {
+		return nil, wrapf(stageErr(StageGenerate, err), "error in progOutput.Flush - overrides")
 	}
-	if err := cmd.Wait(); err != nil {
-		return nil, wrapf(err, "erorr in ProgOutput.Flush - wait args(%s)", c.workDir)
+	if err := c.writeNotices(w); err != nil {
+		return nil, wrapf(stageErr(StageGenerate, err), "error in progOutput.Flush - notices")
 	}
-	return h.Sum(nil), nil
+	return c.sum(buf.Bytes()), nil
 }
 
 func newProgOutput(b *baseOutputBuilder) *progOutput {
@@ -24,10 +24,11 @@ func Test_progOutput_Flush(t *testing.T) {
 	workDir := filepath.Join(testDir, "work_flush")
 	goSumDir := filepath.Join(testDir, "goSum")
 	tests := []struct {
-		name    string
-		builder OutputBuilder
-		want    string
-		wantErr bool
+		name       string
+		builder    OutputBuilder
+		want       string
+		wantErr    bool
+		wantGenErr bool
 	}{
 		{
 			name: "basic",
@@ -52,7 +53,8 @@ func Test_progOutput_Flush(t *testing.T) {
 				Binary(binFile).
 				GoSumFile(filepath.Join(goSumDir, "go.sum")).
 				Prog(filepath.Join(testDir, "foo")),
-			wantErr: true,
+			wantErr:    true,
+			wantGenErr: true,
 		},
 	}
 	for _, tt := range tests {
@@ -67,6 +69,12 @@ func Test_progOutput_Flush(t *testing.T) {
 				t.Errorf("progOutput.Flush() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantGenErr {
+				var g *GeneratorError
+				assert.ErrorAs(t, err, &g, "progOutput.Flush() error")
+				assert.Equal(t, filepath.Join(testDir, "foo"), g.Cmd, "GeneratorError.Cmd")
+				assert.Equal(t, []string{workDir}, g.Args, "GeneratorError.Args")
+			}
 			if err == nil {
 				assert.Equal(t,
 					fmt.Sprintf("%x", sha256.Sum256([]byte(tt.want))),
@@ -4,8 +4,12 @@
 package ac
 
 import (
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
@@ -45,10 +49,11 @@ func Test_baseOutput_modules(t *testing.T) {
 	assert.Nil(t, err, "check")
 	binDir := filepath.Join(cwd, "testdata", "binDir")
 	tests := []struct {
-		name    string
-		builder OutputBuilder
-		want    []string
-		wantErr bool
+		name      string
+		builder   OutputBuilder
+		want      []string
+		wantErr   bool
+		wantErrIs error
 	}{
 		{
 			name:    "basic",
@@ -57,13 +62,28 @@ func Test_baseOutput_modules(t *testing.T) {
 				"gopkg.in/yaml.v2",
 			},
 		}, {
-			name:    "not exists",
-			builder: NewOutputBuilder().Binary(filepath.Join(binDir, "foo")),
-			wantErr: true,
+			name:      "not exists",
+			builder:   NewOutputBuilder().Binary(filepath.Join(binDir, "foo")),
+			wantErr:   true,
+			wantErrIs: os.ErrNotExist,
 		}, {
-			name:    "not binary",
-			builder: NewOutputBuilder().Binary(filepath.Join(binDir, "test.txt")),
-			wantErr: true,
+			name:      "not binary",
+			builder:   NewOutputBuilder().Binary(filepath.Join(binDir, "test.txt")),
+			wantErr:   true,
+			wantErrIs: ErrNotGoBinary,
+		}, {
+			name: "not binary(failed to run)",
+			builder: NewOutputBuilder().Binary(filepath.Join(binDir, "test.txt")).
+				Runner(RunnerFunc(func(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+					return exec.ErrNotFound
+				})),
+			wantErr:   true,
+			wantErrIs: exec.ErrNotFound,
+		}, {
+			name:      "no modules",
+			builder:   NewOutputBuilder().Binary(filepath.Join(cwd, "testdata", "goSum")),
+			wantErr:   true,
+			wantErrIs: ErrNoModules,
 		},
 	}
 	for _, tt := range tests {
@@ -75,6 +95,12 @@ func Test_baseOutput_modules(t *testing.T) {
 				t.Errorf("baseOutput.modules() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrIs, "baseOutput.modules() error")
+			}
+			if errors.Is(tt.wantErrIs, ErrNotGoBinary) == false {
+				assert.False(t, errors.Is(err, ErrNotGoBinary), "baseOutput.modules() error")
+			}
 		})
 	}
 }
@@ -108,6 +134,48 @@ func Test_baseOutput_writePruned(t *testing.T) {
 gopkg.in/yaml.v2 v2.2.2/go.mod h1:hI93XBmqTisBFMUTm0b8Fm+jr3Dg1NNxqwp+5A1VGuI=
 `,
 			wantOutFile: filepath.Join(workDir, "go.sum"),
+		}, {
+			name: "go.sum files",
+			builder: NewOutputBuilder().WorkDir(workDir).GoSumFiles([]string{
+				filepath.Join(testDir, "goWork", "b", "go.sum"),
+				filepath.Join(testDir, "goWork", "a", "go.sum"),
+			}),
+			args: args{
+				modules: []string{
+					"gopkg.in/yaml.v2",
+					"github.com/davecgh/go-spew",
+				},
+			},
+			want: `github.com/davecgh/go-spew v1.1.0 h1:ZDRjVQ15GmhC3fiQ8ni8+OwkZQO4DARzQgrnXU1Liz8=
+gopkg.in/yaml.v2 v2.2.2 h1:ZCJp+EgiOT7lHqUV2J862kp8Qj64Jo6az82+3Td9dZw=
+gopkg.in/yaml.v2 v2.2.2/go.mod h1:hI93XBmqTisBFMUTm0b8Fm+jr3Dg1NNxqwp+5A1VGuI=
+`,
+			wantOutFile: filepath.Join(workDir, "go.sum"),
+		}, {
+			name:    "go.work",
+			builder: NewOutputBuilder().WorkDir(workDir).GoWork(filepath.Join(testDir, "goWork", "go.work")),
+			args: args{
+				modules: []string{
+					"gopkg.in/yaml.v2",
+					"github.com/davecgh/go-spew",
+					"github.com/pmezard/go-difflib",
+				},
+			},
+			want: `gopkg.in/yaml.v2 v2.2.2 h1:ZCJp+EgiOT7lHqUV2J862kp8Qj64Jo6az82+3Td9dZw=
+gopkg.in/yaml.v2 v2.2.2/go.mod h1:hI93XBmqTisBFMUTm0b8Fm+jr3Dg1NNxqwp+5A1VGuI=
+github.com/davecgh/go-spew v1.1.0 h1:ZDRjVQ15GmhC3fiQ8ni8+OwkZQO4DARzQgrnXU1Liz8=
+github.com/pmezard/go-difflib v1.0.0 h1:4DBwDE0NGyQoBHbLQYPwSUPoCMWR5BEzIk/f1lZbAQM=
+`,
+			wantOutFile: filepath.Join(workDir, "go.sum"),
+		}, {
+			name:    "go.work not exists",
+			builder: NewOutputBuilder().WorkDir(workDir).GoWork(filepath.Join(testDir, "goWork", "foo")),
+			args: args{
+				modules: []string{
+					"gopkg.in/yaml.v2",
+				},
+			},
+			wantErr: true,
 		}, {
 			name:    "go.sum not exists",
 			builder: NewOutputBuilder().WorkDir(workDir).GoSumFile(filepath.Join(goSumDir, "foo")),
@@ -138,6 +206,10 @@ gopkg.in/yaml.v2 v2.2.2/go.mod h1:hI93XBmqTisBFMUTm0b8Fm+jr3Dg1NNxqwp+5A1VGuI=
 			if (err != nil) != tt.wantErr {
 				t.Errorf("baseOutput.writePruned() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if err != nil {
+				var p *PruneError
+				assert.ErrorAs(t, err, &p, "baseOutput.writePruned() error")
+			}
 			assert.Equal(t, tt.wantOutFile, gotOutFile, "baseOutput.writePruned() outFile")
 
 			if err == nil {
@@ -148,3 +220,18 @@ gopkg.in/yaml.v2 v2.2.2/go.mod h1:hI93XBmqTisBFMUTm0b8Fm+jr3Dg1NNxqwp+5A1VGuI=
 		})
 	}
 }
+
+func TestOutput_OptionalInterfaces(t *testing.T) {
+	for _, o := range []Output{
+		NewOutputBuilder().Build(),
+		NewOutputBuilder().Prog("gocredits").Build(),
+		NewOutputBuilder().runFunc(nil).Build(),
+	} {
+		_, ok := o.(ExclusionReporter)
+		assert.True(t, ok, "ExclusionReporter")
+		_, ok = o.(Digester)
+		assert.True(t, ok, "Digester")
+		_, ok = o.(PackageReporter)
+		assert.True(t, ok, "PackageReporter")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Override replaces the license of the module that has no (or a wrong) license file
+// in its repository.
+//
+// Version is the constraint of the module version(ie. "v1.2.3", ">=v1.2.0,<v2.0.0").
+// Empty Version matches any version.
+// LicenseText is used if it is not empty, otherwise the content of LicenseFile(read from FS of OutputBuilder) is used.
+type Override struct {
+	Path        string `json:"path"`
+	Version     string `json:"version,omitempty"`
+	LicenseFile string `json:"licenseFile,omitempty"`
+	LicenseText string `json:"licenseText,omitempty"`
+	SPDXID      string `json:"spdxId,omitempty"`
+}
+
+func (o Override) String() string {
+	if o.Version == "" {
+		return o.Path
+	}
+	return o.Path + "(" + o.Version + ")"
+}
+
+// Match reports whether the override is applied to m.
+func (o Override) Match(m Module) bool {
+	if o.Path != m.Path {
+		return false
+	}
+	for _, c := range strings.Split(o.Version, ",") {
+		if matchVersion(strings.TrimSpace(c), m.Version) == false {
+			return false
+		}
+	}
+	return true
+}
+
+// license returns the license text, LicenseFile is read from fsys.
+func (o Override) license(fsys fs.FS) (string, error) {
+	switch {
+	case o.LicenseText != "":
+		return o.LicenseText, nil
+	case o.LicenseFile != "":
+		b, err := fs.ReadFile(fsys, o.LicenseFile)
+		if err != nil {
+			return "", wrapf(err, "reading license file of %s", o)
+		}
+		return string(b), nil
+	case o.SPDXID != "":
+		return "SPDX-License-Identifier: " + o.SPDXID + "\n", nil
+	}
+	return "", fmt.Errorf("override %s has no license", o)
+}
+
+func matchVersion(constraint, version string) bool {
+	ops := []struct {
+		op   string
+		test func(int) bool
+	}{
+		{">=", func(c int) bool { return c >= 0 }},
+		{"<=", func(c int) bool { return c <= 0 }},
+		{">", func(c int) bool { return c > 0 }},
+		{"<", func(c int) bool { return c < 0 }},
+		{"=", func(c int) bool { return c == 0 }},
+	}
+	if constraint == "" {
+		return true
+	}
+	for _, o := range ops {
+		if strings.HasPrefix(constraint, o.op) {
+			return o.test(compareVersion(version, strings.TrimSpace(constraint[len(o.op):])))
+		}
+	}
+	return version == constraint
+}
+
+type appliedOverride struct {
+	module   Module
+	override Override
+}
+
+// applyOverrides splits mods into modules that are not overridden and overridden modules.
+func applyOverrides(overrides []Override, mods []Module) ([]Module, []appliedOverride) {
+	rest := []Module{}
+	applied := []appliedOverride{}
+	for _, m := range mods {
+		found := false
+		for _, o := range overrides {
+			if o.Match(m) {
+				applied = append(applied, appliedOverride{module: m, override: o})
+				found = true
+				break
+			}
+		}
+		if found == false {
+			rest = append(rest, m)
+		}
+	}
+	return rest, applied
+}
+
+func unusedOverrides(overrides []Override, applied []appliedOverride) []Override {
+	ret := []Override{}
+	for _, o := range overrides {
+		used := false
+		for _, a := range applied {
+			if a.override == o {
+				used = true
+				break
+			}
+		}
+		if used == false {
+			ret = append(ret, o)
+		}
+	}
+	return ret
+}
+
+// writeOverrides writes the licenses of the overridden modules
+// by the same format as the default template of gocredits.
+func writeOverrides(w io.Writer, fsys fs.FS, applied []appliedOverride) error {
+	for _, a := range applied {
+		l, err := a.override.license(fsys)
+		if err != nil {
+			return err
+		}
+		if err := writeLicense(w, a.module.Path, "https://"+a.module.Path, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLicense(w io.Writer, name, url, content string) error {
+	_, err := fmt.Fprintf(w, "%s\n%s\n%s\n%s\n%s\n\n", name, url,
+		strings.Repeat("-", 64),
+		content,
+		strings.Repeat("=", 64),
+	)
+	return err
+}
+
+type overridesConfig struct {
+	Overrides []Override `json:"overrides"`
+}
+
+// LoadOverrides reads overrides from the config file(JSON).
+// The relative path in licenseFile is resolved from the directory of the config file.
+//
+//	{
+//	  "overrides": [
+//	    {"path": "example.com/foo", "version": ">=v1.0.0", "licenseFile": "licenses/foo", "spdxId": "MIT"}
+//	  ]
+//	}
+func LoadOverrides(name string) ([]Override, error) {
+	return LoadOverridesFS(OSFS(), name)
+}
+
+// LoadOverridesFS reads overrides from the config file in fsys(the name is the path of the OS).
+func LoadOverridesFS(fsys fs.FS, name string) ([]Override, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, wrapf(err, "LoadOverrides")
+	}
+	defer f.Close()
+	c := overridesConfig{}
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return nil, wrapf(err, "LoadOverrides decoding '%s'", name)
+	}
+	dir := filepath.Dir(name)
+	for i, o := range c.Overrides {
+		if o.Path == "" {
+			return nil, fmt.Errorf("LoadOverrides: path is empty in overrides[%d]", i)
+		}
+		if o.LicenseFile != "" && filepath.IsAbs(o.LicenseFile) == false {
+			c.Overrides[i].LicenseFile = filepath.Join(dir, o.LicenseFile)
+		}
+	}
+	return c.Overrides, nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverride_Match(t *testing.T) {
+	m := Module{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"}
+	tests := []struct {
+		name     string
+		override Override
+		want     bool
+	}{
+		{
+			name:     "any version",
+			override: Override{Path: "gopkg.in/yaml.v2"},
+			want:     true,
+		}, {
+			name:     "exact",
+			override: Override{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"},
+			want:     true,
+		}, {
+			name:     "exact unmatch",
+			override: Override{Path: "gopkg.in/yaml.v2", Version: "v2.2.1"},
+			want:     false,
+		}, {
+			name:     "range",
+			override: Override{Path: "gopkg.in/yaml.v2", Version: ">=v2.2.0, <v2.3.0"},
+			want:     true,
+		}, {
+			name:     "range unmatch",
+			override: Override{Path: "gopkg.in/yaml.v2", Version: ">v2.2.2"},
+			want:     false,
+		}, {
+			name:     "path unmatch",
+			override: Override{Path: "gopkg.in/yaml.v3"},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.override.Match(m), "Override.Match()")
+		})
+	}
+}
+
+func Test_applyOverrides(t *testing.T) {
+	mods := []Module{
+		{Path: "example.com/foo", Version: "v1.0.0"},
+		{Path: "example.com/bar", Version: "v0.1.0"},
+	}
+	overrides := []Override{
+		{Path: "example.com/bar", LicenseText: "bar"},
+		{Path: "example.com/baz", LicenseText: "baz"},
+	}
+	rest, applied := applyOverrides(overrides, mods)
+	assert.Equal(t, []Module{{Path: "example.com/foo", Version: "v1.0.0"}}, rest, "rest")
+	assert.Equal(t, []appliedOverride{{module: mods[1], override: overrides[0]}}, applied, "applied")
+	assert.Equal(t, []Override{overrides[1]}, unusedOverrides(overrides, applied), "unused")
+}
+
+func TestLoadOverrides(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	workDir := filepath.Join(cwd, "testdata", "work_overrides")
+	tests := []struct {
+		name    string
+		config  string
+		want    []Override
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			config: `{"overrides": [
+  {"path": "example.com/foo", "version": "v1.0.0", "licenseFile": "licenses/foo", "spdxId": "MIT"},
+  {"path": "example.com/bar", "licenseText": "bar", "spdxId": "BSD-3-Clause"}
+]}`,
+			want: []Override{
+				{Path: "example.com/foo", Version: "v1.0.0", LicenseFile: filepath.Join(workDir, "licenses", "foo"), SPDXID: "MIT"},
+				{Path: "example.com/bar", LicenseText: "bar", SPDXID: "BSD-3-Clause"},
+			},
+		}, {
+			name:    "empty path",
+			config:  `{"overrides": [{"licenseText": "bar"}]}`,
+			wantErr: true,
+		}, {
+			name:    "invalid",
+			config:  `{"overrides": [`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			configFile := filepath.Join(workDir, "overrides.json")
+			err = ioutil.WriteFile(configFile, []byte(tt.config), 0644)
+			assert.Nil(t, err, "check")
+
+			got, err := LoadOverrides(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadOverrides() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got, "LoadOverrides()")
+		})
+	}
+}
+
+func TestLoadOverridesFS(t *testing.T) {
+	workDir := filepath.Join(string(filepath.Separator), "work")
+	m := NewMemFS()
+	err := m.MkdirAll(filepath.Join(workDir, "licenses"), os.ModePerm)
+	assert.Nil(t, err, "check")
+	err = m.WriteFile(filepath.Join(workDir, "overrides.json"), []byte(`{"overrides": [
+  {"path": "example.com/foo", "licenseFile": "licenses/foo"}
+]}`), 0644)
+	assert.Nil(t, err, "check")
+	err = m.WriteFile(filepath.Join(workDir, "licenses", "foo"), []byte("foo license"), 0644)
+	assert.Nil(t, err, "check")
+
+	got, err := LoadOverridesFS(m, filepath.Join(workDir, "overrides.json"))
+	assert.Nil(t, err, "LoadOverridesFS()")
+	assert.Equal(t, []Override{
+		{Path: "example.com/foo", LicenseFile: filepath.Join(workDir, "licenses", "foo")},
+	}, got, "LoadOverridesFS()")
+
+	// licenseFile も同じ FS から読む.
+	b := &strings.Builder{}
+	err = writeOverrides(b, m, []appliedOverride{
+		{override: got[0], module: Module{Path: "example.com/foo", Version: "v1.0.0"}},
+	})
+	assert.Nil(t, err, "writeOverrides()")
+	assert.Contains(t, b.String(), "foo license", "writeOverrides()")
+	err = writeOverrides(b, NewMemFS(), []appliedOverride{
+		{override: got[0], module: Module{Path: "example.com/foo", Version: "v1.0.0"}},
+	})
+	assert.ErrorIs(t, err, fs.ErrNotExist, "writeOverrides()")
+}
+
+func Test_funcOutput_Flush_With_Overrides(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	binFile := filepath.Join(testDir, "binDir", "my_cmd")
+	workDir := filepath.Join(testDir, "work_flush")
+	goSumFile := filepath.Join(testDir, "goSum", "go.sum")
+	runFunc := func(argv []string, outStream, errStream io.Writer) error {
+		// go.sum pruned by the modules that are not overridden.
+		b, err := ioutil.ReadFile(filepath.Join(argv[0], "go.sum"))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(outStream, strings.NewReader("go.sum: "+string(b)+"\n"))
+		return err
+	}
+	tests := []struct {
+		name      string
+		overrides []Override
+		want      string
+		wantErr   string
+	}{
+		{
+			name: "basic",
+			overrides: []Override{
+				{Path: "gopkg.in/yaml.v2", Version: "v2.2.2", LicenseText: "yaml license", SPDXID: "Apache-2.0"},
+			},
+			want: "go.sum: \n" +
+				"gopkg.in/yaml.v2\nhttps://gopkg.in/yaml.v2\n" +
+				strings.Repeat("-", 64) + "\nyaml license\n" + strings.Repeat("=", 64) + "\n\n",
+		}, {
+			name: "unused",
+			overrides: []Override{
+				{Path: "gopkg.in/yaml.v2", Version: "v2.2.1", LicenseText: "yaml license"},
+			},
+			want: "go.sum: gopkg.in/yaml.v2 v2.2.2 h1:ZCJp+EgiOT7lHqUV2J862kp8Qj64Jo6az82+3Td9dZw=\n" +
+				"gopkg.in/yaml.v2 v2.2.2/go.mod h1:hI93XBmqTisBFMUTm0b8Fm+jr3Dg1NNxqwp+5A1VGuI=\n\n",
+			wantErr: "override gopkg.in/yaml.v2(v2.2.1) is not used in '" + binFile + "'\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			got := &strings.Builder{}
+			gotErr := &strings.Builder{}
+			_, err = NewOutputBuilder().
+				WorkDir(workDir).
+				Binary(binFile).
+				GoSumFile(goSumFile).
+				Overrides(tt.overrides).
+				runFunc(runFunc).
+				OutStream(got).
+				ErrStream(gotErr).
+				Build().
+				Flush()
+			assert.Nil(t, err, "funcOutput.Flush()")
+			assert.Equal(t, tt.want, got.String(), "funcOutput.Flush() outStream")
+			assert.Equal(t, tt.wantErr, gotErr.String(), "funcOutput.Flush() errStream")
+		})
+	}
+}
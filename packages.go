@@ -0,0 +1,234 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/gosym"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrNoPclntab is returned when the symbol table(pclntab) is not found in the binary.
+var ErrNoPclntab = errors.New("pclntab not found")
+
+// ModulePackages is the packages that are linked into the binary from the module.
+type ModulePackages struct {
+	Module
+	Packages []string `json:"packages"`
+}
+
+// pclntab magic numbers(go1.2, go1.16, go1.18, go1.20).
+var pclntabMagics = [][]byte{
+	{0xfb, 0xff, 0xff, 0xff},
+	{0xfa, 0xff, 0xff, 0xff},
+	{0xf0, 0xff, 0xff, 0xff},
+	{0xf1, 0xff, 0xff, 0xff},
+}
+
+// searchPclntab searches the header of pclntab in data(ie. the stripped PE binary).
+func searchPclntab(data []byte) []byte {
+	for i := 0; i+8 <= len(data); i += 4 {
+		for _, m := range pclntabMagics {
+			if bytes.Equal(data[i:i+4], m) && data[i+4] == 0 && data[i+5] == 0 &&
+				(data[i+6] == 1 || data[i+6] == 2 || data[i+6] == 4) && (data[i+7] == 4 || data[i+7] == 8) {
+				return data[i:]
+			}
+		}
+	}
+	return nil
+}
+
+func elfPclntab(f *elf.File) ([]byte, uint64, error) {
+	var text uint64
+	if s := f.Section(".text"); s != nil {
+		text = s.Addr
+	}
+	for _, n := range []string{".gopclntab", ".data.rel.ro.gopclntab"} {
+		if s := f.Section(n); s != nil {
+			b, err := s.Data()
+			return b, text, err
+		}
+	}
+	// PIE 等で .gopclntab が無い場合は runtime.pclntab シンボルから.
+	syms, err := f.Symbols()
+	if err != nil {
+		return nil, 0, ErrNoPclntab
+	}
+	var start, end *elf.Symbol
+	for i := range syms {
+		switch syms[i].Name {
+		case "runtime.pclntab":
+			start = &syms[i]
+		case "runtime.epclntab":
+			end = &syms[i]
+		}
+	}
+	if start == nil || end == nil || start.Section != end.Section || int(start.Section) >= len(f.Sections) {
+		return nil, 0, ErrNoPclntab
+	}
+	s := f.Sections[start.Section]
+	if start.Value < s.Addr || end.Value < s.Addr {
+		return nil, 0, ErrNoPclntab
+	}
+	b, err := s.Data()
+	if err != nil {
+		return nil, 0, err
+	}
+	p, ok := sliceRange(b, start.Value-s.Addr, end.Value-s.Addr)
+	if ok == false {
+		return nil, 0, ErrNoPclntab
+	}
+	return p, text, nil
+}
+
+// sliceRange returns b[start:end] if the range is in b.
+func sliceRange(b []byte, start, end uint64) ([]byte, bool) {
+	if start > end || end > uint64(len(b)) {
+		return nil, false
+	}
+	return b[start:end], true
+}
+
+func machoPclntab(f *macho.File) ([]byte, uint64, error) {
+	var text uint64
+	if s := f.Section("__text"); s != nil {
+		text = s.Addr
+	}
+	s := f.Section("__gopclntab")
+	if s == nil {
+		return nil, 0, ErrNoPclntab
+	}
+	b, err := s.Data()
+	return b, text, err
+}
+
+func pePclntab(f *pe.File) ([]byte, uint64, error) {
+	var imageBase uint64
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(h.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = h.ImageBase
+	}
+	var text uint64
+	if s := f.Section(".text"); s != nil {
+		text = imageBase + uint64(s.VirtualAddress)
+	}
+	var start, end *pe.Symbol
+	for _, s := range f.Symbols {
+		switch s.Name {
+		case "runtime.pclntab":
+			start = s
+		case "runtime.epclntab":
+			end = s
+		}
+	}
+	if start != nil && end != nil && start.SectionNumber == end.SectionNumber &&
+		start.SectionNumber > 0 && int(start.SectionNumber) <= len(f.Sections) {
+		b, err := f.Sections[start.SectionNumber-1].Data()
+		if err != nil {
+			return nil, 0, err
+		}
+		p, ok := sliceRange(b, uint64(start.Value), uint64(end.Value))
+		if ok == false {
+			return nil, 0, ErrNoPclntab
+		}
+		return p, text, nil
+	}
+	// -s で strip された場合は .rdata を探す.
+	if s := f.Section(".rdata"); s != nil {
+		b, err := s.Data()
+		if err != nil {
+			return nil, 0, err
+		}
+		if p := searchPclntab(b); p != nil {
+			return p, text, nil
+		}
+	}
+	return nil, 0, ErrNoPclntab
+}
+
+// readPclntab returns pclntab and the address of the text section in the binary(ELF, PE, Mach-O).
+func readPclntab(binary string) ([]byte, uint64, error) {
+	if f, err := elf.Open(binary); err == nil {
+		defer f.Close()
+		return elfPclntab(f)
+	}
+	if f, err := macho.Open(binary); err == nil {
+		defer f.Close()
+		return machoPclntab(f)
+	}
+	if f, err := pe.Open(binary); err == nil {
+		defer f.Close()
+		return pePclntab(f)
+	}
+	return nil, 0, fmt.Errorf("readPclntab '%s': %w", binary, ErrNotGoBinary)
+}
+
+// ReadPackages returns the import paths of the packages that are linked into the binary.
+// They are read from the symbol table(pclntab), so the packages whose functions are all inlined are not listed.
+func ReadPackages(binary string) ([]string, error) {
+	data, text, err := readPclntab(binary)
+	if err != nil {
+		return nil, wrapf(err, "ReadPackages '%s'", binary)
+	}
+	t, err := gosym.NewTable(nil, gosym.NewLineTable(data, text))
+	if err != nil {
+		return nil, wrapf(err, "ReadPackages '%s'", binary)
+	}
+	seen := map[string]bool{}
+	pkgs := []string{}
+	for _, f := range t.Funcs {
+		// go1.13 等では最後の要素の "." がエスケープされている(gopkg.in/yaml%2ev2).
+		p := strings.ReplaceAll(f.PackageName(), "%2e", ".")
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// AttributePackages returns the packages of each module.
+// The package is attributed to the module that has the longest matching path.
+// Packages that are not in mods(ie. the standard library, the main module) are ignored.
+func AttributePackages(mods []Module, pkgs []string) []ModulePackages {
+	ret := make([]ModulePackages, len(mods))
+	for i, m := range mods {
+		ret[i] = ModulePackages{Module: m, Packages: []string{}}
+	}
+	for _, p := range pkgs {
+		found := -1
+		for i, m := range mods {
+			if (p == m.Path || strings.HasPrefix(p, m.Path+"/")) &&
+				(found < 0 || len(m.Path) > len(mods[found].Path)) {
+				found = i
+			}
+		}
+		if found >= 0 {
+			ret[found].Packages = append(ret[found].Packages, p)
+		}
+	}
+	return ret
+}
+
+// readModulePackages returns the packages of the resolved modules.
+func (c *baseOutput) readModulePackages() ([]ModulePackages, error) {
+	if c.source.enabled() {
+		return AttributePackages(c.mods, c.listedPackages), nil
+	}
+	pkgs, err := ReadPackages(c.binary)
+	if err != nil {
+		return nil, err
+	}
+	return AttributePackages(c.mods, pkgs), nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPackages(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	binDir := filepath.Join(cwd, "testdata", "binDir")
+
+	got, err := ReadPackages(filepath.Join(binDir, "my_cmd"))
+	assert.Nil(t, err, "ReadPackages()")
+	assert.Contains(t, got, "gopkg.in/yaml.v2", "ReadPackages()")
+	assert.Contains(t, got, "main", "ReadPackages()")
+	assert.Contains(t, got, "runtime", "ReadPackages()")
+
+	_, err = ReadPackages(filepath.Join(binDir, "test.txt"))
+	assert.ErrorIs(t, err, ErrNotGoBinary, "ReadPackages()")
+}
+
+func TestAttributePackages(t *testing.T) {
+	tests := []struct {
+		name string
+		mods []Module
+		pkgs []string
+		want []ModulePackages
+	}{
+		{
+			name: "basic",
+			mods: []Module{
+				{Path: "golang.org/x/tools", Version: "v0.1.0"},
+				{Path: "golang.org/x/tools/gopls", Version: "v0.2.0"},
+				{Path: "github.com/foo/bar", Version: "v1.0.0"},
+			},
+			pkgs: []string{
+				"fmt",
+				"main",
+				"golang.org/x/tools/go/packages",
+				"golang.org/x/tools/gopls/internal/lsp",
+				"golang.org/x/toolsx",
+			},
+			want: []ModulePackages{
+				{Module: Module{Path: "golang.org/x/tools", Version: "v0.1.0"}, Packages: []string{"golang.org/x/tools/go/packages"}},
+				{Module: Module{Path: "golang.org/x/tools/gopls", Version: "v0.2.0"}, Packages: []string{"golang.org/x/tools/gopls/internal/lsp"}},
+				{Module: Module{Path: "github.com/foo/bar", Version: "v1.0.0"}, Packages: []string{}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AttributePackages(tt.mods, tt.pkgs), "AttributePackages()")
+		})
+	}
+}
+
+func Test_baseOutput_Packages(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	workDir := filepath.Join(testDir, "work_packages")
+	runFunc := func(argv []string, outStream, errStream io.Writer) error {
+		_, err := io.Copy(outStream, strings.NewReader("test"))
+		return err
+	}
+	tests := []struct {
+		name    string
+		builder OutputBuilder
+		want    []ModulePackages
+	}{
+		{
+			name: "binary",
+			builder: NewOutputBuilder().
+				Binary(filepath.Join(testDir, "binDir", "my_cmd")).
+				Packages(true),
+			want: []ModulePackages{
+				{Module: Module{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"}, Packages: []string{"gopkg.in/yaml.v2"}},
+			},
+		}, {
+			name: "source",
+			builder: NewOutputBuilder().
+				Source(Source{Patterns: []string{"./..."}}).
+				listFunc(func(dir string, args []string, env []string, outStream, errStream io.Writer) error {
+					_, err := io.Copy(outStream, strings.NewReader(testGoList))
+					return err
+				}).
+				Packages(true),
+			want: []ModulePackages{
+				{Module: Module{Path: "github.com/davecgh/go-spew", Version: "v1.1.0"}, Packages: []string{
+					"github.com/davecgh/go-spew/spew", "github.com/davecgh/go-spew/spew/internal",
+				}},
+				{Module: Module{Path: "github.com/pmezard/go-difflib", Version: "v1.0.0"}, Packages: []string{
+					"github.com/pmezard/go-difflib/difflib",
+				}},
+			},
+		}, {
+			name: "disabled",
+			builder: NewOutputBuilder().
+				Binary(filepath.Join(testDir, "binDir", "my_cmd")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			o := tt.builder.
+				WorkDir(workDir).
+				GoSumFile(filepath.Join(testDir, "goSum", "go.sum")).
+				OutStream(ioutil.Discard).
+				runFunc(runFunc).
+				Build()
+			_, err = o.Flush()
+			assert.Nil(t, err, "Flush()")
+			assert.Equal(t, tt.want, o.(PackageReporter).Packages(), "Packages()")
+		})
+	}
+}
+
+func Test_sliceRange(t *testing.T) {
+	b := []byte("pclntab")
+	tests := []struct {
+		name   string
+		start  uint64
+		end    uint64
+		want   []byte
+		wantOk bool
+	}{
+		{name: "basic", start: 1, end: 3, want: []byte("cl"), wantOk: true},
+		{name: "all", start: 0, end: 7, want: b, wantOk: true},
+		{name: "end out of range", start: 1, end: 8},
+		{name: "start after end", start: 3, end: 1},
+		{name: "underflow", start: ^uint64(0), end: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sliceRange(b, tt.start, tt.end)
+			assert.Equal(t, tt.want, got, "sliceRange()")
+			assert.Equal(t, tt.wantOk, ok, "sliceRange() ok")
+		})
+	}
+}
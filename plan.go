@@ -0,0 +1,149 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// PlanEntry is what Dist.Run does for the platform directory.
+type PlanEntry struct {
+	// Dir is the name of the platform directory in DistDir(ie. linux_amd64).
+	Dir string
+	// Binary is the path of the binary that is chosen in Dir.
+	// It is the path of Dir if no Go executable is found in Dir.
+	// If the binary is in the archive, it is the path in WorkDir where the binary is extracted.
+	Binary string
+	// Archive is the path of the archive in DistDir(ie. my_cmd_linux_amd64.tar.gz) and
+	// ArchiveEntry is the name of the binary in the archive.
+	// Dir is the name of the archive without the extension.
+	// If the binary is in the image tarball, Image is the path of the tarball,
+	// ImageLayer is the name of the layer in the tarball and ArchiveEntry is the path in the image.
+	Archive      string
+	ArchiveEntry string
+	Image        string
+	ImageLayer   string
+	// Os and Arch are parsed from Dir.
+	Os   string
+	Arch string
+	// ReplacedOs and ReplacedArch are replaced by ReplaceOs and ReplaceArch.
+	ReplacedOs   string
+	ReplacedArch string
+	// OutFile is the path of the output file(before merged by uniq).
+	OutFile string
+}
+
+// Platform returns the platform name that is used in the output file name(ie. Linux_i386).
+func (e PlanEntry) Platform() string {
+	return e.ReplacedOs + "_" + e.ReplacedArch
+}
+
+// Plan is the list of PlanEntry.
+type Plan []PlanEntry
+
+// WriteTable writes the plan as a table.
+func (p Plan) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIR\tBINARY\tOS\tARCH\tREPLACED\tOUTPUT")
+	for _, e := range p {
+		binary := e.Binary
+		if e.Archive != "" {
+			binary = e.Archive + ":" + e.ArchiveEntry
+		}
+		if e.Image != "" {
+			binary = e.Image + ":" + e.ArchiveEntry
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Dir, binary, e.Os, e.Arch, e.Platform(), e.OutFile)
+	}
+	return tw.Flush()
+}
+
+func (p Plan) String() string {
+	b := &strings.Builder{}
+	p.WriteTable(b)
+	return b.String()
+}
+
+// findBinary returns the first Go executable in dir.
+// It returns dir if no Go executable is found(`go version -m` scans dir).
+func findBinary(fsys fs.FS, dir string) (string, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return "", wrapf(err, "findBinary")
+	}
+	for _, f := range files {
+		if f.Type().IsRegular() == false {
+			continue
+		}
+		p := filepath.Join(dir, f.Name())
+		if isGoExecutableFS(fsys, p) {
+			return p, nil
+		}
+	}
+	return dir, nil
+}
+
+func (d *baseDist) plan() (Plan, error) {
+	p := Plan{}
+	if d.image != "" {
+		i, err := d.planImage(d.image)
+		if err != nil {
+			return nil, wrapf(err, "plan")
+		}
+		p = append(p, i...)
+		if d.distDir == "" {
+			return p, nil
+		}
+	}
+	dirs, err := fs.ReadDir(d.fs, d.distDir)
+	if err != nil {
+		return nil, wrapf(err, "plan")
+	}
+	for _, f := range dirs {
+		if _, ok := archiveBase(f.Name()); ok && f.Type().IsRegular() {
+			e, err := d.planArchive(f.Name())
+			if errors.Is(err, ErrNotGoBinary) {
+				// Go のバイナリを含まないアーカイブ(ソースコード等)は対象外.
+				continue
+			}
+			if err != nil {
+				return nil, wrapf(err, "plan")
+			}
+			e.OutFile = filepath.Join(d.outDir, d.outputs[0].fileName(e.Platform()))
+			p = append(p, e)
+			continue
+		}
+		if f.IsDir() == false {
+			continue
+		}
+		binary, err := findBinary(d.fs, filepath.Join(d.distDir, f.Name()))
+		if err != nil {
+			return nil, wrapf(err, "plan")
+		}
+		s := append(DistSuffix(f.Name()), "")
+		e := PlanEntry{
+			Dir:          f.Name(),
+			Binary:       binary,
+			Os:           s[0],
+			Arch:         s[1],
+			ReplacedOs:   ReplaceItem(d.replaceOs, s[0]),
+			ReplacedArch: ReplaceItem(d.replaceArch, s[1]),
+		}
+		e.OutFile = filepath.Join(d.outDir, d.outputs[0].fileName(e.Platform()))
+		p = append(p, e)
+	}
+	return p, nil
+}
+
+// Plan returns what Run does without running any generator or writing files.
+func (d *baseDist) Plan() (Plan, error) {
+	return d.plan()
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_baseDist_Plan(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	tests := []struct {
+		name    string
+		builder DistBuilder
+		want    Plan
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			builder: NewDistBuilder().
+				DistDir(distDir).
+				OutDir(outDir).
+				ReplaceOs([][]string{
+					[]string{"linux", "Linux"},
+				}).
+				ReplaceArch([][]string{
+					[]string{"386", "i386"},
+				}),
+			want: Plan{
+				{
+					Dir:          "linux_386",
+					Binary:       filepath.Join(distDir, "linux_386", "my_cmd"),
+					Os:           "linux",
+					Arch:         "386",
+					ReplacedOs:   "Linux",
+					ReplacedArch: "i386",
+					OutFile:      filepath.Join(outDir, "CREDITS_Linux_i386"),
+				}, {
+					Dir:          "linux_amd64",
+					Binary:       filepath.Join(distDir, "linux_amd64", "my_cmd"),
+					Os:           "linux",
+					Arch:         "amd64",
+					ReplacedOs:   "Linux",
+					ReplacedArch: "amd64",
+					OutFile:      filepath.Join(outDir, "CREDITS_Linux_amd64"),
+				}, {
+					Dir:          "linux_amd64_v1",
+					Binary:       filepath.Join(distDir, "linux_amd64_v1", "my_cmd"),
+					Os:           "linux",
+					Arch:         "amd64_v1",
+					ReplacedOs:   "Linux",
+					ReplacedArch: "amd64_v1",
+					OutFile:      filepath.Join(outDir, "CREDITS_Linux_amd64_v1"),
+				},
+			},
+		}, {
+			name: "dist not exists",
+			builder: NewDistBuilder().
+				DistDir(filepath.Join(testDir, "foo")).
+				OutDir(outDir),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.builder.Build().(Planner).Plan()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("baseDist.Plan() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got, "baseDist.Plan()")
+			_, err = os.Stat(outDir)
+			assert.True(t, os.IsNotExist(err), "no files are written")
+		})
+	}
+}
+
+func TestPlan_WriteTable(t *testing.T) {
+	p := Plan{
+		{
+			Dir:          "linux_386",
+			Binary:       "dist/linux_386/my_cmd",
+			Os:           "linux",
+			Arch:         "386",
+			ReplacedOs:   "Linux",
+			ReplacedArch: "i386",
+			OutFile:      "CREDITS_Linux_i386",
+		},
+	}
+	got := &strings.Builder{}
+	err := p.WriteTable(got)
+	assert.Nil(t, err, "Plan.WriteTable()")
+	assert.Equal(t, `DIR        BINARY                 OS     ARCH  REPLACED    OUTPUT
+linux_386  dist/linux_386/my_cmd  linux  386   Linux_i386  CREDITS_Linux_i386
+`, got.String(), "Plan.WriteTable()")
+	assert.Equal(t, got.String(), p.String(), "Plan.String()")
+}
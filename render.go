@@ -0,0 +1,117 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/hankei6km/go-ac/credits"
+)
+
+// RenderInput is the result of the platform that is shared by the outputs of Dist.
+type RenderInput struct {
+	Platform string
+	Binary   string
+	// Modules are the resolved modules(before excluded).
+	Modules  []Module
+	Excluded []Exclusion
+	// Packages are available if OutputBuilder.Packages(true) is set.
+	Packages []ModulePackages
+	// Credits is the output of the Output(the CREDITS text).
+	Credits []byte
+}
+
+// Renderer renders the file of DistOutput.
+type Renderer func(w io.Writer, in RenderInput) error
+
+// RenderText writes the CREDITS text.
+func RenderText(w io.Writer, in RenderInput) error {
+	_, err := w.Write(in.Credits)
+	return err
+}
+
+// RenderJSON writes the modules and the licenses as JSON.
+// The platform is not included so that the files of the platforms can be uniqed.
+func RenderJSON(w io.Writer, in RenderInput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Modules  []Module         `json:"modules"`
+		Excluded []Exclusion      `json:"excluded,omitempty"`
+		Packages []ModulePackages `json:"packages,omitempty"`
+		Credits  []credits.Entry  `json:"credits"`
+	}{
+		Modules:  in.Modules,
+		Excluded: in.Excluded,
+		Packages: in.Packages,
+		Credits:  credits.Parse(string(in.Credits)),
+	})
+}
+
+// DistOutput is the named output of Dist.
+type DistOutput struct {
+	// Name is the name of the output(ie. "credits", "json").
+	Name string
+	// BaseName is the base name of the file.
+	// The platform is inserted before the extension(ie. CREDITS.json -> CREDITS_linux_amd64.json).
+	BaseName string
+	// Uniq merges the files of all platforms into BaseName if they have the same content.
+	Uniq bool
+	// Render renders the file. RenderText is used if it is nil.
+	Render Renderer
+
+	// legacy is set for the default output(Outputs is not set).
+	// The platform is appended to BaseName(ie. CREDITS.txt -> CREDITS.txt_linux_amd64).
+	legacy bool
+}
+
+func (o DistOutput) render(w io.Writer, in RenderInput) error {
+	if o.Render == nil {
+		return RenderText(w, in)
+	}
+	return o.Render(w, in)
+}
+
+// fileName returns the file name of the output for the platform(suffix).
+func (o DistOutput) fileName(suffix string) string {
+	if o.legacy {
+		return o.BaseName + "_" + suffix
+	}
+	return distFileName(o.BaseName, suffix)
+}
+
+// fileSuffix returns the suffix(platform) of name that is returned by fileName.
+func (o DistOutput) fileSuffix(name string) (string, bool) {
+	if o.legacy {
+		if len(name) <= len(o.BaseName)+1 || strings.HasPrefix(name, o.BaseName+"_") == false {
+			return "", false
+		}
+		return name[len(o.BaseName)+1:], true
+	}
+	return distFileSuffix(o.BaseName, name)
+}
+
+// filePattern returns the glob pattern of the files of the platforms.
+func (o DistOutput) filePattern() string {
+	return o.fileName("*")
+}
+
+// distFileName returns the file name of the platform(suffix) that is inserted before the extension.
+func distFileName(baseName, suffix string) string {
+	ext := filepath.Ext(baseName)
+	return strings.TrimSuffix(baseName, ext) + "_" + suffix + ext
+}
+
+// distFileSuffix returns the suffix(platform) of name that is returned by distFileName.
+func distFileSuffix(baseName, name string) (string, bool) {
+	ext := filepath.Ext(baseName)
+	prefix := strings.TrimSuffix(baseName, ext) + "_"
+	if len(name) <= len(prefix)+len(ext) || strings.HasPrefix(name, prefix) == false || strings.HasSuffix(name, ext) == false {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(ext)], true
+}
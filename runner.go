@@ -0,0 +1,89 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// Runner runs the commands(the go toolchain and the external programs).
+// If env is nil, the command inherits the environment of the current process.
+type Runner interface {
+	Run(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// RunnerFunc is the function that implements Runner.
+type RunnerFunc func(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+func (f RunnerFunc) Run(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return f(ctx, name, args, env, stdin, stdout, stderr)
+}
+
+// execRunner implements Runner by os/exec.
+type execRunner struct{}
+
+// ExecRunner returns Runner that runs the commands by os/exec(the default).
+func ExecRunner() Runner {
+	return execRunner{}
+}
+
+func (execRunner) Run(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// Command is the command that is recorded by RecordingRunner.
+type Command struct {
+	Name string
+	Args []string
+	Env  []string
+}
+
+// RecordingRunner records the commands, and runs them by Runner.
+// If Runner is nil, the commands are only recorded(they succeed without output).
+// It can be used as the fake in tests(with RunnerFunc), or to audit the commands.
+type RecordingRunner struct {
+	Runner Runner
+
+	mu       sync.Mutex
+	commands []Command
+}
+
+func (r *RecordingRunner) Run(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	r.mu.Lock()
+	r.commands = append(r.commands, Command{
+		Name: name,
+		Args: append([]string{}, args...),
+		Env:  append([]string(nil), env...),
+	})
+	r.mu.Unlock()
+	if r.Runner == nil {
+		return nil
+	}
+	return r.Runner.Run(ctx, name, args, env, stdin, stdout, stderr)
+}
+
+// Commands returns the recorded commands.
+func (r *RecordingRunner) Commands() []Command {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Command{}, r.commands...)
+}
+
+// runnerListFunc returns listFuncType that runs `go list` by the runner.
+func runnerListFunc(r Runner) listFuncType {
+	return func(dir string, args []string, env []string, outStream, errStream io.Writer) error {
+		if dir != "" {
+			args = append([]string{"-C", dir}, args...)
+		}
+		return r.Run(context.Background(), "go", args, env, nil, outStream, errStream)
+	}
+}
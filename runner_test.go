@@ -0,0 +1,135 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFakeRunner returns RunnerFunc that fakes `go version -m` and the external program.
+func testFakeRunner() RunnerFunc {
+	return func(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		switch {
+		case name == "go" && len(args) > 1 && args[0] == "version":
+			_, err := fmt.Fprintf(stdout, "%s: go1.21.0\n\tpath\texample.com/my_cmd\n\tmod\texample.com/my_cmd\t(devel)\t\n\tdep\tgopkg.in/yaml.v2\tv2.2.2\th1:ZCJp+EgiOT7lHqUV2J862kp8Qj64Jo6az82+3Td9dZw=\n", args[len(args)-1])
+			return err
+		case name == "gocredits":
+			_, err := io.Copy(stdout, strings.NewReader("credits: "+args[0]))
+			return err
+		}
+		return fmt.Errorf("unexpected command: %s %v", name, args)
+	}
+}
+
+func TestRecordingRunner(t *testing.T) {
+	r := &RecordingRunner{Runner: ExecRunner()}
+	out := &strings.Builder{}
+	err := r.Run(context.Background(), "go", []string{"env", "GOOS"}, nil, nil, out, io.Discard)
+	assert.Nil(t, err, "RecordingRunner.Run()")
+	assert.Equal(t, runtime.GOOS, strings.TrimSpace(out.String()), "RecordingRunner.Run()")
+
+	err = (&RecordingRunner{}).Run(context.Background(), "not_exist_cmd", nil, nil, nil, out, io.Discard)
+	assert.Nil(t, err, "RecordingRunner.Run() records only")
+
+	assert.Equal(t, []Command{{Name: "go", Args: []string{"env", "GOOS"}}}, r.Commands(), "RecordingRunner.Commands()")
+}
+
+func Test_progOutput_Flush_With_Runner(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	workDir := filepath.Join(testDir, "work_runner")
+	goSumDir := filepath.Join(testDir, "goSum")
+	err = ResetDir(workDir, os.ModePerm)
+	assert.Nil(t, err, "check")
+	defer os.RemoveAll(workDir)
+
+	r := &RecordingRunner{Runner: testFakeRunner()}
+	out := &strings.Builder{}
+	_, err = NewOutputBuilder().
+		WorkDir(workDir).
+		Binary("my_cmd"). // 存在しなくても良い.
+		GoSumFile(filepath.Join(goSumDir, "go.sum")).
+		Prog("gocredits").
+		Runner(r).
+		OutStream(out).
+		Build().
+		Flush()
+	assert.Nil(t, err, "progOutput.Flush()")
+	assert.Equal(t, "credits: "+workDir, out.String(), "progOutput.Flush()")
+	assert.Equal(t, []Command{
+		{Name: "go", Args: []string{"version", "-m", "my_cmd"}},
+		{Name: "gocredits", Args: []string{workDir}},
+	}, r.Commands(), "commands")
+}
+
+func Test_baseDist_Run_With_Runner(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	distDir := filepath.Join(testDir, "distDir")
+	outDir := filepath.Join(testDir, "outDir")
+	workDir := filepath.Join(testDir, "work_dist")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	for _, d := range []string{workDir, outDir} {
+		err = ResetDir(d, os.ModePerm)
+		assert.Nil(t, err, "check")
+		defer os.RemoveAll(d)
+	}
+
+	r := &RecordingRunner{Runner: testFakeRunner()}
+	err = NewDistBuilder().
+		DistDir(distDir).
+		OutDir(outDir).
+		WorkDir(workDir).
+		Dedup(true).
+		Runner(r).
+		OutputBuilder(
+			NewOutputBuilder().
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				Prog("gocredits"),
+		).
+		Build().
+		Run()
+	assert.Nil(t, err, "baseDist.Run()")
+
+	got := []string{}
+	for _, c := range r.Commands() {
+		got = append(got, c.Name+" "+strings.Join(c.Args, " "))
+	}
+	assert.Equal(t, []string{
+		"go version -m " + filepath.Join(distDir, "linux_386", "my_cmd"),
+		"go version -m " + filepath.Join(distDir, "linux_amd64", "my_cmd"),
+		"go version -m " + filepath.Join(distDir, "linux_amd64_v1", "my_cmd"),
+		"gocredits " + workDir,
+	}, got, "commands")
+}
+
+func Test_sourceModules_With_Runner(t *testing.T) {
+	r := &RecordingRunner{Runner: RunnerFunc(func(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		_, err := io.Copy(stdout, strings.NewReader(testGoList))
+		return err
+	})}
+	c := NewOutputBuilder().
+		Source(Source{Dir: "src", Patterns: []string{"./cmd/my_cmd"}, GOOS: "windows"}).
+		Runner(r).
+		Build().(*funcOutput)
+	_, err := c.sourceModules()
+	assert.Nil(t, err, "sourceModules()")
+	cmds := r.Commands()
+	assert.Equal(t, 1, len(cmds), "commands")
+	assert.Equal(t, "go", cmds[0].Name, "commands")
+	assert.Equal(t, []string{"-C", "src", "list", "-deps", "-json", "./cmd/my_cmd"}, cmds[0].Args, "commands")
+	assert.Contains(t, cmds[0].Env, "GOOS=windows", "commands")
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Source is the package pattern and the build constraints that are used instead of the binary.
+// The modules are read by `go list -deps -json`, so CREDITS can be written before the binary is built.
+type Source struct {
+	// Dir is the directory where `go list` runs(ie. the root of the main module).
+	Dir string
+	// Patterns are the package patterns(ie. ./cmd/my_cmd).
+	Patterns []string
+	// GOOS and GOARCH are passed to `go list` if they are not empty.
+	GOOS   string
+	GOARCH string
+	// Tags are the build tags.
+	Tags []string
+}
+
+func (s Source) enabled() bool {
+	return len(s.Patterns) > 0
+}
+
+func (s Source) String() string {
+	return fmt.Sprintf("%s(%s/%s)", strings.Join(s.Patterns, " "), s.GOOS, s.GOARCH)
+}
+
+func (s Source) args() []string {
+	args := []string{"list", "-deps", "-json"}
+	if len(s.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(s.Tags, ","))
+	}
+	return append(args, s.Patterns...)
+}
+
+func (s Source) env() []string {
+	env := os.Environ()
+	if s.GOOS != "" {
+		env = append(env, "GOOS="+s.GOOS)
+	}
+	if s.GOARCH != "" {
+		env = append(env, "GOARCH="+s.GOARCH)
+	}
+	return env
+}
+
+// listFuncType defines type of function that runs `go list`.
+type listFuncType func(dir string, args []string, env []string, outStream, errStream io.Writer) error
+
+// SourceOutputBuilder adds properties to Output(Builder).
+type SourceOutputBuilder interface {
+	Source(Source) OutputBuilder
+	listFunc(listFuncType) OutputBuilder
+}
+
+func (b *baseOutputBuilder) Source(source Source) OutputBuilder {
+	bb := b.branch()
+	bb.source = source
+	return bb
+}
+
+func (b *baseOutputBuilder) listFunc(listFunc listFuncType) OutputBuilder {
+	bb := b.branch()
+	bb.listFuncIntl = listFunc
+	return bb
+}
+
+type listedPackage struct {
+	ImportPath string
+	Standard   bool
+	Module     *struct {
+		Path    string
+		Version string
+		Main    bool
+	}
+}
+
+// sourceModules returns the modules of the packages that are listed by `go list -deps -json`.
+// The main module and the standard library are not included.
+func (c *baseOutput) sourceModules() ([]Module, error) {
+	args := c.source.args()
+	out := &bytes.Buffer{}
+	errStream := &strings.Builder{}
+	if err := c.listFunc(c.source.Dir, args, c.source.env(), out, errStream); err != nil {
+		return nil, wrapf(newGeneratorError("go", args, errStream.String(), err), "sourceModules()")
+	}
+	seen := map[string]bool{}
+	mods := []Module{}
+	c.listedPackages = []string{}
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		p := listedPackage{}
+		if err := dec.Decode(&p); err != nil {
+			return nil, wrapf(err, "sourceModules() decoding the output of go list")
+		}
+		if p.Standard || p.Module == nil || p.Module.Main {
+			continue
+		}
+		c.listedPackages = append(c.listedPackages, p.ImportPath)
+		m := Module{Path: p.Module.Path, Version: p.Module.Version}
+		if seen[m.Path] {
+			continue
+		}
+		seen[m.Path] = true
+		mods = append(mods, m)
+	}
+	if len(mods) == 0 {
+		return nil, fmt.Errorf("sourceModules() '%s': %w", c.source, ErrNoModules)
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+	return mods, nil
+}
+
+// loadModules returns the modules from the source if it is set, otherwise from the binary.
+func (c *baseOutput) loadModules() ([]Module, error) {
+	if c.source.enabled() {
+		return c.sourceModules()
+	}
+	return c.readModules()
+}
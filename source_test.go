@@ -0,0 +1,130 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testGoList = `{"ImportPath": "fmt", "Standard": true}
+{"ImportPath": "github.com/pmezard/go-difflib/difflib", "Module": {"Path": "github.com/pmezard/go-difflib", "Version": "v1.0.0"}}
+{"ImportPath": "github.com/davecgh/go-spew/spew", "Module": {"Path": "github.com/davecgh/go-spew", "Version": "v1.1.0"}}
+{"ImportPath": "github.com/davecgh/go-spew/spew/internal", "Module": {"Path": "github.com/davecgh/go-spew", "Version": "v1.1.0"}}
+{"ImportPath": "example.com/my_cmd", "Module": {"Path": "example.com/my_cmd", "Main": true}}
+`
+
+func Test_baseOutput_sourceModules(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	workDir := filepath.Join(testDir, "work_source")
+	goSumDir := filepath.Join(testDir, "goSum")
+
+	type listed struct {
+		dir  string
+		args []string
+		env  []string
+	}
+	tests := []struct {
+		name       string
+		source     Source
+		out        string
+		listErr    error
+		want       []Module
+		wantArgs   []string
+		wantEnv    []string
+		wantErrIs  error
+		wantGenErr bool
+	}{
+		{
+			name:   "basic",
+			source: Source{Dir: "src", Patterns: []string{"./cmd/my_cmd"}},
+			out:    testGoList,
+			want: []Module{
+				{Path: "github.com/davecgh/go-spew", Version: "v1.1.0"},
+				{Path: "github.com/pmezard/go-difflib", Version: "v1.0.0"},
+			},
+			wantArgs: []string{"list", "-deps", "-json", "./cmd/my_cmd"},
+		}, {
+			name:     "constraints",
+			source:   Source{Dir: "src", Patterns: []string{"./..."}, GOOS: "windows", GOARCH: "386", Tags: []string{"foo", "bar"}},
+			out:      testGoList,
+			want:     []Module{{Path: "github.com/davecgh/go-spew", Version: "v1.1.0"}, {Path: "github.com/pmezard/go-difflib", Version: "v1.0.0"}},
+			wantArgs: []string{"list", "-deps", "-json", "-tags", "foo,bar", "./..."},
+			wantEnv:  []string{"GOOS=windows", "GOARCH=386"},
+		}, {
+			name:      "no modules",
+			source:    Source{Dir: "src", Patterns: []string{"./cmd/my_cmd"}},
+			out:       `{"ImportPath": "fmt", "Standard": true}`,
+			wantArgs:  []string{"list", "-deps", "-json", "./cmd/my_cmd"},
+			wantErrIs: ErrNoModules,
+		}, {
+			name:       "go list error",
+			source:     Source{Dir: "src", Patterns: []string{"./cmd/my_cmd"}},
+			listErr:    errors.New("exit status 1"),
+			wantArgs:   []string{"list", "-deps", "-json", "./cmd/my_cmd"},
+			wantGenErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			got := listed{}
+			o := NewOutputBuilder().
+				WorkDir(workDir).
+				GoSumFile(filepath.Join(goSumDir, "go.sum")).
+				Source(tt.source).
+				listFunc(func(dir string, args []string, env []string, outStream, errStream io.Writer) error {
+					got = listed{dir: dir, args: args, env: env}
+					if tt.listErr != nil {
+						fmt.Fprintln(errStream, "no Go files")
+						return tt.listErr
+					}
+					_, err := io.Copy(outStream, strings.NewReader(tt.out))
+					return err
+				}).
+				runFunc(func(argv []string, outStream, errStream io.Writer) error {
+					_, err := io.Copy(outStream, strings.NewReader("test"))
+					return err
+				}).
+				OutStream(ioutil.Discard).
+				Build()
+
+			_, err = o.Flush()
+			assert.Equal(t, tt.source.Dir, got.dir, "dir")
+			assert.Equal(t, tt.wantArgs, got.args, "args")
+			for _, e := range tt.wantEnv {
+				assert.Contains(t, got.env, e, "env")
+			}
+			switch {
+			case tt.wantErrIs != nil:
+				assert.ErrorIs(t, err, tt.wantErrIs, "Flush()")
+			case tt.wantGenErr:
+				var g *GeneratorError
+				if assert.ErrorAs(t, err, &g, "Flush()") {
+					assert.Equal(t, "no Go files\n", g.Stderr, "GeneratorError.Stderr")
+				}
+			default:
+				assert.Nil(t, err, "Flush()")
+				assert.Equal(t, tt.want, o.(*funcOutput).resolved(), "resolved()")
+				b, err := ioutil.ReadFile(filepath.Join(workDir, "go.sum"))
+				assert.Nil(t, err, "check")
+				assert.Contains(t, string(b), "github.com/davecgh/go-spew v1.1.0", "pruned go.sum")
+				assert.NotContains(t, string(b), "github.com/magefile/mage", "pruned go.sum")
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+module "example.com/b" // comment
+
+go 1.21
@@ -31,12 +31,70 @@ func DistSuffix(d string) []string {
 	if l < 2 {
 		return s
 	}
-	if verSuffixRegExp.MatchString(s[l-1]) { // かなり良くない対処。
+	if l > 2 && verSuffixRegExp.MatchString(s[l-1]) { // かなり良くない対処。
 		return []string{s[l-3], strings.Join(s[l-2:], "_")}
 	}
 	return s[l-2:]
 }
 
+// knownOS and knownArch are the values of GOOS and GOARCH(`go tool dist list` and the reserved ones).
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+		"illumos": true, "ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true, "openbsd": true,
+		"plan9": true, "solaris": true, "wasip1": true, "windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true, "arm64be": true,
+		"loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true, "mips64p32": true,
+		"mips64p32le": true, "ppc": true, "ppc64": true, "ppc64le": true, "riscv": true, "riscv64": true,
+		"s390": true, "s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+	// GOAMD64, GOARM, GO386, GOMIPS, GOPPC64, GORISCV64 等の値(image の variant も含む).
+	archVariantRegExp = regexp.MustCompile(`^(v[0-9][0-9.]*|[0-9]+|sse2|softfloat|hardfloat|power[0-9]+|rva[0-9a-z]+)$`)
+)
+
+// isKnownArch reports whether arch is GOARCH(with the variant, ie. amd64_v1).
+func isKnownArch(arch string) bool {
+	s := strings.Split(arch, "_")
+	switch len(s) {
+	case 1:
+		return knownArch[s[0]]
+	case 2:
+		return knownArch[s[0]] && archVariantRegExp.MatchString(s[1])
+	}
+	return false
+}
+
+// isPlatformSuffix reports whether s ends with the platform(os_arch[_variant]).
+// The names replaced by replaceOs / replaceArch are also accepted.
+// The binary name may precede the platform(ie. my_cmd_linux_amd64).
+func isPlatformSuffix(s string, replaceOs, replaceArch [][]string) bool {
+	_, _, ok := platformSuffix(s, replaceOs, replaceArch)
+	return ok
+}
+
+// platformSuffix returns os and arch of s that ends with the platform(see isPlatformSuffix).
+// The replaced names are returned as they are(ie. my_cmd_1.0.0_Linux_x86_64 -> Linux, x86_64).
+func platformSuffix(s string, replaceOs, replaceArch [][]string) (string, string, bool) {
+	replaced := func(r [][]string, v string) bool {
+		for _, r := range r {
+			if len(r) > 1 && r[1] == v {
+				return true
+			}
+		}
+		return false
+	}
+	t := strings.Split(s, "_")
+	for i := len(t) - 2; i >= 0 && i >= len(t)-3; i-- {
+		o, a := t[i], strings.Join(t[i+1:], "_")
+		if (knownOS[o] || replaced(replaceOs, o)) && (isKnownArch(a) || replaced(replaceArch, a)) {
+			return o, a, true
+		}
+	}
+	return "", "", false
+}
+
 // ReplaceItem replaces s by r.
 func ReplaceItem(r [][]string, s string) string {
 	for _, r := range r {
@@ -46,3 +104,89 @@ func ReplaceItem(r [][]string, s string) string {
 	}
 	return s
 }
+
+// restoreItem restores s that is replaced by r(the inverse of ReplaceItem).
+func restoreItem(r [][]string, s string) string {
+	for _, r := range r {
+		if len(r) > 1 && r[1] == s {
+			return r[0]
+		}
+	}
+	return s
+}
+
+// compareVersion compares semantic versions(ie. v1.2.3, v1.2.3-pre, v0.0.0-20191109021931-daa7c04131f5).
+// It returns -1, 0 or +1.
+func compareVersion(a, b string) int {
+	splitVer := func(v string) ([]string, string) {
+		v = strings.TrimPrefix(strings.SplitN(v, "+", 2)[0], "v")
+		t := strings.SplitN(v, "-", 2)
+		pre := ""
+		if len(t) > 1 {
+			pre = t[1]
+		}
+		return strings.Split(t[0], "."), pre
+	}
+	cmpNum := func(a, b string) int {
+		a = strings.TrimLeft(a, "0")
+		b = strings.TrimLeft(b, "0")
+		switch {
+		case len(a) < len(b):
+			return -1
+		case len(a) > len(b):
+			return 1
+		}
+		return strings.Compare(a, b)
+	}
+	na, pa := splitVer(a)
+	nb, pb := splitVer(b)
+	for i := 0; i < len(na) || i < len(nb); i++ {
+		x, y := "0", "0"
+		if i < len(na) {
+			x = na[i]
+		}
+		if i < len(nb) {
+			y = nb[i]
+		}
+		if c := cmpNum(x, y); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case pa == pb:
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	}
+	// prerelease は "." で区切った識別子ごとに比較する(数値は数値として、数値は英数字より前).
+	isNum := func(s string) bool {
+		return s != "" && strings.Trim(s, "0123456789") == ""
+	}
+	ia, ib := strings.Split(pa, "."), strings.Split(pb, ".")
+	for i := 0; i < len(ia) && i < len(ib); i++ {
+		x, y := ia[i], ib[i]
+		var c int
+		switch {
+		case isNum(x) && isNum(y):
+			c = cmpNum(x, y)
+		case isNum(x):
+			c = -1
+		case isNum(y):
+			c = 1
+		default:
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(ia) < len(ib):
+		return -1
+	case len(ia) > len(ib):
+		return 1
+	}
+	return 0
+}
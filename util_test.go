@@ -101,6 +101,13 @@ func TestDistSuffix(t *testing.T) {
 			},
 			want: []string{"linux", "amd64_v1"},
 		},
+		{
+			name: "version only",
+			args: args{
+				d: "tool_v1",
+			},
+			want: []string{"tool", "v1"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -149,3 +156,78 @@ func TestReplaceItem(t *testing.T) {
 		})
 	}
 }
+
+func Test_compareVersion(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.10.0", -1},
+		{"v2.0.0", "v1.10.0", 1},
+		{"v1.2.3-pre", "v1.2.3", -1},
+		{"v1.2.3", "v1.2.3-pre", 1},
+		{"v0.0.0-20191109021931-daa7c04131f5", "v0.0.0-20200101000000-aaaaaaaaaaaa", -1},
+		{"v1.2.3+incompatible", "v1.2.3", 0},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+		{"v1.0.0-rc.10", "v1.0.0-rc.2", 1},
+		{"v1.0.0-rc.1", "v1.0.0-rc.1.1", -1},
+		{"v1.0.0-1", "v1.0.0-alpha", -1},
+		{"v1.0.0-alpha", "v1.0.0-beta", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, compareVersion(tt.a, tt.b), "compareVersion()")
+		})
+	}
+}
+
+func Test_isPlatformSuffix(t *testing.T) {
+	replaceOs := [][]string{{"linux", "Linux"}}
+	replaceArch := [][]string{{"386", "i386"}, {"amd64_v1", "x86_64"}}
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: "linux_386", want: true},
+		{s: "linux_amd64_v1", want: true},
+		{s: "linux_arm_7", want: true},
+		{s: "my_cmd_linux_arm64_v8", want: true},
+		{s: "Linux_i386", want: true},
+		{s: "Linux_x86_64", want: true},
+		{s: "notes.md", want: false},
+		{s: "linux_amd64_notes", want: false},
+		{s: "v1", want: false},
+		{s: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPlatformSuffix(tt.s, replaceOs, replaceArch), "isPlatformSuffix()")
+		})
+	}
+}
+
+func Test_platformSuffix(t *testing.T) {
+	replaceOs := [][]string{{"linux", "Linux"}}
+	replaceArch := [][]string{{"amd64", "x86_64"}}
+	tests := []struct {
+		s        string
+		wantOs   string
+		wantArch string
+		wantOk   bool
+	}{
+		{s: "my_cmd_linux_amd64_v1", wantOs: "linux", wantArch: "amd64_v1", wantOk: true},
+		{s: "my_cmd_1.0.0_Linux_x86_64", wantOs: "Linux", wantArch: "x86_64", wantOk: true},
+		{s: "tool_v1", wantOk: false},
+		{s: "my_cmd_source", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			gotOs, gotArch, gotOk := platformSuffix(tt.s, replaceOs, replaceArch)
+			assert.Equal(t, tt.wantOs, gotOs, "platformSuffix() os")
+			assert.Equal(t, tt.wantArch, gotArch, "platformSuffix() arch")
+			assert.Equal(t, tt.wantOk, gotOk, "platformSuffix() ok")
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// parseVendorModules returns the versions of the modules in vendor/modules.txt.
+func parseVendorModules(fsys fs.FS, name string) (map[string]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ret := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if strings.HasPrefix(l, "# ") == false {
+			// "## explicit" 等とパッケージの行.
+			continue
+		}
+		// # path version [=> replacement [version]]
+		t := strings.Fields(l[2:])
+		v := ""
+		if len(t) > 1 && t[1] != "=>" {
+			v = t[1]
+		}
+		ret[t[0]] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// checkVendor returns VendorError if mods are not vendored or vendored at the different version.
+func checkVendor(vendored map[string]string, mods []Module) error {
+	e := &VendorError{}
+	for _, m := range mods {
+		v, ok := vendored[m.Path]
+		switch {
+		case ok == false:
+			e.Missing = append(e.Missing, m)
+		case v != m.Version:
+			e.Mismatched = append(e.Mismatched, VendorMismatch{Module: m, Vendored: v})
+		}
+	}
+	if len(e.Missing) > 0 || len(e.Mismatched) > 0 {
+		return e
+	}
+	return nil
+}
+
+// copied from github.com/Songmu/gocredits(github.com/pmezard/licenses).
+var reLicense = regexp.MustCompile(`(?i)^(?:` +
+	`((?:un)?licen[sc]e)|` +
+	`((?:un)?licen[sc]e\.(?:md|markdown|txt))|` +
+	`(copy(?:ing|right)(?:\.[^.]+)?)|` +
+	`(licen[sc]e\.[^.]+)` +
+	`)$`)
+
+func scoreLicenseName(name string) float64 {
+	m := reLicense.FindStringSubmatch(name)
+	switch {
+	case m == nil:
+		break
+	case m[1] != "":
+		return 1.0
+	case m[2] != "":
+		return 0.9
+	case m[3] != "":
+		return 0.8
+	case m[4] != "":
+		return 0.7
+	}
+	return 0.
+}
+
+// findLicenseFile returns the license file in dir.
+func findLicenseFile(fsys fs.FS, dir string) (string, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return "", err
+	}
+	best, name := 0.0, ""
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if s := scoreLicenseName(f.Name()); s > best {
+			best, name = s, f.Name()
+		}
+	}
+	if name == "" {
+		return "", os.ErrNotExist
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func vendorID(vendorDir string) string {
+	if a, err := filepath.Abs(vendorDir); err == nil {
+		vendorDir = a
+	}
+	return "vendor:" + vendorDir
+}
+
+// writeVendorCredits writes the licenses in vendorDir instead of the generator.
+func (c *baseOutput) writeVendorCredits(w io.Writer) error {
+	// GOROOT of the toolchain(not the one that go-ac is built with), it is read from the OS.
+	goRoot, err := c.goEnv("GOROOT")
+	if err == nil {
+		var b []byte
+		if b, err = ioutil.ReadFile(filepath.Join(goRoot, "LICENSE")); err == nil {
+			if err := writeLicense(w, "Go (the standard library)", "https://golang.org/", string(b)); err != nil {
+				return err
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(c.errStream, "the license of Go is not found: %v\n", err)
+	}
+	mods := append([]Module{}, c.generated...)
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+	for _, m := range mods {
+		l, err := findLicenseFile(c.fs, filepath.Join(c.vendorDir, filepath.FromSlash(m.Path)))
+		if err != nil {
+			return wrapf(err, "the license of %s is not found in '%s'", m.Path, c.vendorDir)
+		}
+		b, err := fs.ReadFile(c.fs, l)
+		if err != nil {
+			return err
+		}
+		if err := writeLicense(w, m.Path, "https://"+m.Path, string(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkVendor checks the modules passed to the generator against vendor/modules.txt.
+func (c *baseOutput) checkVendor() error {
+	if c.vendorDir == "" {
+		return nil
+	}
+	vendored, err := parseVendorModules(c.fs, filepath.Join(c.vendorDir, "modules.txt"))
+	if err != nil {
+		return wrapf(err, "checkVendor")
+	}
+	return checkVendor(vendored, c.generated)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2019 hankei6km
+// Licensed under the MIT License. See LICENSE in the project root.
+
+package ac
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseVendorModules(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	got, err := parseVendorModules(OSFS(), filepath.Join(cwd, "testdata", "vendorDir", "ok", "modules.txt"))
+	assert.Nil(t, err, "parseVendorModules()")
+	assert.Equal(t, map[string]string{
+		"gopkg.in/yaml.v2":  "v2.2.2",
+		"example.com/local": "v1.0.0",
+	}, got, "parseVendorModules()")
+}
+
+func Test_baseOutput_Flush_With_VendorDir(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err, "check")
+	testDir := filepath.Join(cwd, "testdata")
+	workDir := filepath.Join(testDir, "work_vendor")
+	vendorDir := filepath.Join(testDir, "vendorDir")
+	tests := []struct {
+		name      string
+		vendorDir string
+		prog      string
+		goRoot    string
+		want      string
+		wantErr   *VendorError
+		wantErrIs error
+		wantStage Stage
+	}{
+		{
+			name:      "basic",
+			vendorDir: filepath.Join(vendorDir, "ok"),
+			want: "gopkg.in/yaml.v2\nhttps://gopkg.in/yaml.v2\n" + strings.Repeat("-", 64) + "\n" +
+				"yaml license\n\n" + strings.Repeat("=", 64) + "\n\n",
+		}, {
+			name:      "GOROOT by the runner",
+			vendorDir: filepath.Join(vendorDir, "ok"),
+			goRoot:    filepath.Join(vendorDir, "goroot"),
+			want: "Go (the standard library)\nhttps://golang.org/\n" + strings.Repeat("-", 64) + "\n" +
+				"go license\n\n" + strings.Repeat("=", 64) + "\n\n" +
+				"gopkg.in/yaml.v2\nhttps://gopkg.in/yaml.v2\n" + strings.Repeat("-", 64) + "\n" +
+				"yaml license\n\n" + strings.Repeat("=", 64) + "\n\n",
+		}, {
+			name:      "with Prog",
+			vendorDir: filepath.Join(vendorDir, "ok"),
+			prog:      "gocredits",
+			wantErrIs: ErrProgWithVendor,
+		}, {
+			name:      "mismatch",
+			vendorDir: filepath.Join(vendorDir, "mismatch"),
+			wantErr: &VendorError{
+				Mismatched: []VendorMismatch{{Module: Module{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"}, Vendored: "v2.2.1"}},
+			},
+			wantStage: StageModules,
+		}, {
+			name:      "missing",
+			vendorDir: filepath.Join(vendorDir, "missing"),
+			wantErr: &VendorError{
+				Missing: []Module{{Path: "gopkg.in/yaml.v2", Version: "v2.2.2"}},
+			},
+			wantStage: StageModules,
+		}, {
+			name:      "no license",
+			vendorDir: filepath.Join(vendorDir, "nolicense"),
+			wantStage: StageGenerate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ResetDir(workDir, os.ModePerm)
+			assert.Nil(t, err, "check")
+			defer os.RemoveAll(workDir)
+
+			got := &strings.Builder{}
+			b := NewOutputBuilder().
+				WorkDir(workDir).
+				Binary(filepath.Join(testDir, "binDir", "my_cmd")).
+				GoSumFile(filepath.Join(testDir, "goSum", "go.sum")).
+				VendorDir(tt.vendorDir).
+				OutStream(got).
+				ErrStream(ioutil.Discard)
+			if tt.prog != "" {
+				b = b.Prog(tt.prog)
+			}
+			if tt.goRoot != "" {
+				b = b.Runner(RunnerFunc(func(ctx context.Context, name string, args []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+					if name == "go" && len(args) == 2 && args[0] == "env" && args[1] == "GOROOT" {
+						_, err := io.WriteString(stdout, tt.goRoot+"\n")
+						return err
+					}
+					return ExecRunner().Run(ctx, name, args, env, stdin, stdout, stderr)
+				}))
+			}
+			_, err = b.Build().Flush()
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrIs, "Flush()")
+				return
+			}
+			if tt.wantStage != "" {
+				var s *StageError
+				if assert.ErrorAs(t, err, &s, "Flush()") {
+					assert.Equal(t, tt.wantStage, s.Stage, "Flush() stage")
+				}
+			}
+			if tt.wantErr != nil {
+				var v *VendorError
+				if assert.ErrorAs(t, err, &v, "Flush()") {
+					assert.Equal(t, tt.wantErr, v, "Flush()")
+				}
+			}
+			if tt.wantStage != "" {
+				return
+			}
+			assert.Nil(t, err, "Flush()")
+			// Go の LICENSE は環境によって異なるので除いて比較.
+			s := got.String()
+			if i := strings.Index(s, "gopkg.in/yaml.v2\n"); i >= 0 && tt.goRoot == "" {
+				s = s[i:]
+			}
+			assert.Equal(t, tt.want, s, "Flush()")
+		})
+	}
+}